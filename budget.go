@@ -0,0 +1,47 @@
+package sse
+
+import "unsafe"
+
+// eventSlotBytes estimates the memory one buffered event slot occupies,
+// excluding the data it points to
+const eventSlotBytes = int64(unsafe.Sizeof(Event{}))
+
+// estimatedBufferBytes estimates the memory reserved by client buffers when
+// every connection slot is in use
+func (c Config) estimatedBufferBytes() int64 {
+	return int64(c.BufferSize) * c.clientSlotBytes()
+}
+
+// clientSlotBytes estimates the memory one BufferSize unit costs across all
+// connections: each client's channel, plus every lane it may create with
+// Config.PerTypeBuffers
+func (c Config) clientSlotBytes() int64 {
+	queues := int64(1)
+	if c.PerTypeBuffers {
+		queues += int64(c.typeLanes())
+	}
+	return int64(c.MaxConnections) * queues * eventSlotBytes
+}
+
+// exceedsMemoryBudget reports whether the buffers would outgrow
+// Config.MemoryBudget
+func (c Config) exceedsMemoryBudget() bool {
+	return c.MemoryBudget > 0 && c.estimatedBufferBytes() > c.MemoryBudget
+}
+
+// clampToBudget shrinks BufferSize until the estimated buffer memory fits
+// Config.MemoryBudget, logging a warning when it has to
+func clampToBudget(c Config) Config {
+	if !c.exceedsMemoryBudget() || c.MaxConnections < 1 {
+		return c
+	}
+
+	size := c.MemoryBudget / c.clientSlotBytes()
+	if size < 1 {
+		size = 1
+	}
+	c.logger().Warnf("sse: BufferSize %d with MaxConnections %d needs about %d bytes, over MemoryBudget %d; clamping BufferSize to %d",
+		c.BufferSize, c.MaxConnections, c.estimatedBufferBytes(), c.MemoryBudget, size)
+	c.BufferSize = int(size)
+	return c
+}
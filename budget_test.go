@@ -0,0 +1,82 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 1_000_000
+	config.MaxConnections = 1000
+	config.MemoryBudget = 64 << 20
+	config.Logger = StdLogger{}
+
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected oversized buffers to fail validation, got %v", err)
+	}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	clamped := server.Config()
+	if clamped.BufferSize >= config.BufferSize || clamped.BufferSize < 1 {
+		t.Errorf("Expected BufferSize clamped below %d, got %d", config.BufferSize, clamped.BufferSize)
+	}
+	if clamped.estimatedBufferBytes() > config.MemoryBudget {
+		t.Errorf("Expected clamped buffers within budget, estimated %d bytes", clamped.estimatedBufferBytes())
+	}
+	if err := clamped.Validate(); err != nil {
+		t.Errorf("Expected clamped config to be valid, got %v", err)
+	}
+	if !strings.Contains(logged.String(), "clamping BufferSize") {
+		t.Errorf("Expected a clamp warning to be logged, got %q", logged.String())
+	}
+}
+
+func TestMemoryBudgetWithinLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MemoryBudget = 1 << 30
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected default buffers to fit the budget, got %v", err)
+	}
+
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+	if server.config.BufferSize != config.BufferSize {
+		t.Errorf("Expected BufferSize left at %d, got %d", config.BufferSize, server.config.BufferSize)
+	}
+}
+
+func TestMemoryBudgetCountsTypeLanes(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnections = 1000
+	config.BufferSize = 1024
+	config.MemoryBudget = 1024 * 1000 * 2 * eventSlotBytes
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected a single buffer per client to fit, got %v", err)
+	}
+
+	// Each client may also hold MaxTypeLanes lanes of BufferSize events
+	config.PerTypeBuffers = true
+	config.MaxTypeLanes = 3
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected per-type lanes to exceed the budget, got %v", err)
+	}
+
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+	if size := server.Config().BufferSize; size != 512 {
+		t.Errorf("Expected BufferSize clamped to 512 for 4 queues per client, got %d", size)
+	}
+}
@@ -0,0 +1,95 @@
+package sse
+
+// BufferPolicy decides what happens when Config.MaxTotalBuffered is reached
+type BufferPolicy int
+
+const (
+	// BufferRejectNew drops new broadcasts until clients catch up
+	BufferRejectNew BufferPolicy = iota
+	// BufferDropOldest discards the oldest queued events from the most backed-up clients
+	BufferDropOldest
+	// BufferCloseSlowest disconnects the most backed-up clients
+	BufferCloseSlowest
+)
+
+// TotalBuffered returns the number of events queued across all clients
+func (s *Server) TotalBuffered() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.totalBufferedLocked()
+}
+
+// totalBufferedLocked sums queued events. Callers must hold s.mu.
+func (s *Server) totalBufferedLocked() int {
+	total := 0
+	for _, client := range s.clients {
+		total += client.queued()
+	}
+	return total
+}
+
+// enforceBufferCap applies Config.BufferPolicy once the global buffer cap is
+// reached. It reports whether the pending broadcast may be enqueued.
+func (s *Server) enforceBufferCap() bool {
+	if s.config.MaxTotalBuffered <= 0 {
+		return true
+	}
+
+	s.mu.RLock()
+	total := s.totalBufferedLocked()
+	s.mu.RUnlock()
+
+	if total < s.config.MaxTotalBuffered {
+		return true
+	}
+
+	switch s.config.BufferPolicy {
+	case BufferDropOldest:
+		for total >= s.config.MaxTotalBuffered {
+			client := s.slowestClient(nil)
+			if client == nil {
+				break
+			}
+			if client.dropOldest() {
+				total--
+			} else {
+				// Drained concurrently by the client's own loop
+				total = s.TotalBuffered()
+			}
+		}
+		return true
+	case BufferCloseSlowest:
+		closing := make(map[string]bool)
+		for total >= s.config.MaxTotalBuffered {
+			client := s.slowestClient(closing)
+			if client == nil {
+				break
+			}
+			closing[client.ID] = true
+			total -= client.queued()
+			// Removal may wait on a client stuck mid-write, so do it asynchronously
+			go s.disconnectClient(client, CloseReasonBufferLimit)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// slowestClient returns the client with the most queued events, ignoring
+// those in exclude, or nil if nothing is queued
+func (s *Server) slowestClient(exclude map[string]bool) *Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var slowest *Client
+	for _, client := range s.clients {
+		if exclude[client.ID] {
+			continue
+		}
+		if client.queued() > 0 && (slowest == nil || client.queued() > slowest.queued()) {
+			slowest = client
+		}
+	}
+	return slowest
+}
@@ -0,0 +1,142 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallWriter is a ResponseRecorder whose writes block while stalled,
+// simulating a slow client
+type stallWriter struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	stalled bool
+	release chan struct{}
+}
+
+func newStallWriter() *stallWriter {
+	return &stallWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		release:          make(chan struct{}),
+	}
+}
+
+func (w *stallWriter) stall() {
+	w.mu.Lock()
+	w.stalled = true
+	w.mu.Unlock()
+}
+
+func (w *stallWriter) unstall() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stalled {
+		w.stalled = false
+		close(w.release)
+	}
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	stalled, release := w.stalled, w.release
+	w.mu.Unlock()
+
+	if stalled {
+		<-release
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+// connectStalled opens n connections and stalls their writers once connected
+func connectStalled(server *Server, n int) []*stallWriter {
+	writers := make([]*stallWriter, n)
+	for i := range writers {
+		writers[i] = newStallWriter()
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go server.HandleSSE(writers[i], req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	for _, w := range writers {
+		w.stall()
+	}
+	return writers
+}
+
+func TestMaxTotalBufferedPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy BufferPolicy
+		check  func(t *testing.T, server *Server)
+	}{
+		{"reject new", BufferRejectNew, func(t *testing.T, server *Server) {
+			if total := server.TotalBuffered(); total > 30+3 {
+				t.Errorf("Expected total buffered to stay near cap, got %d", total)
+			}
+			if count := server.GetConnectionCount(); count != 3 {
+				t.Errorf("Expected all clients to stay connected, got %d", count)
+			}
+		}},
+		{"drop oldest", BufferDropOldest, func(t *testing.T, server *Server) {
+			if total := server.TotalBuffered(); total > 30+3 {
+				t.Errorf("Expected total buffered to stay near cap, got %d", total)
+			}
+			if count := server.GetConnectionCount(); count != 3 {
+				t.Errorf("Expected all clients to stay connected, got %d", count)
+			}
+		}},
+		{"close slowest", BufferCloseSlowest, func(t *testing.T, server *Server) {
+			if count := server.GetConnectionCount(); count >= 3 {
+				t.Errorf("Expected slow clients to be closed, got %d connections", count)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.BufferSize = 100
+			config.MaxTotalBuffered = 30
+			config.BufferPolicy = tt.policy
+			server := NewServerWithConfig(config)
+
+			writers := connectStalled(server, 3)
+
+			for i := 0; i < 50; i++ {
+				server.Broadcast(Event{Type: "tick", Data: i})
+			}
+
+			// Let any asynchronous removals settle
+			time.Sleep(50 * time.Millisecond)
+			tt.check(t, server)
+
+			for _, w := range writers {
+				w.unstall()
+			}
+			server.Shutdown()
+		})
+	}
+}
+
+func TestTotalBuffered(t *testing.T) {
+	server := NewServer()
+	writers := connectStalled(server, 2)
+
+	for i := 0; i < 5; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+
+	// Each stalled client holds one event in its blocked write
+	if total := server.TotalBuffered(); total < 8 || total > 10 {
+		t.Errorf("Expected about 10 buffered events, got %d", total)
+	}
+
+	for _, w := range writers {
+		w.unstall()
+	}
+	server.Shutdown()
+}
@@ -0,0 +1,42 @@
+package sse
+
+import "time"
+
+// Capabilities is what a connection negotiated, sent in the connection
+// event when Config.AnnounceCapabilities is set
+type Capabilities struct {
+	Encoding          string `json:"encoding"`              // Config.Encoders key, or "json" for the default
+	Compression       string `json:"compression"`           // content encoding of the stream, "identity" when uncompressed
+	HeartbeatInterval int64  `json:"heartbeat_interval_ms"` // how often heartbeats are sent
+	Retry             int    `json:"retry_ms"`              // reconnection delay sent in the retry field
+}
+
+// connectionData returns the data of a client's connection event
+func (s *Server) connectionData(client *Client) map[string]interface{} {
+	data := map[string]interface{}{
+		"client_id": client.ID,
+		"timestamp": time.Now().Unix(),
+	}
+	if s.config.AnnounceCapabilities {
+		data["capabilities"] = s.capabilities(client)
+	}
+	return data
+}
+
+// capabilities returns what client negotiated
+func (s *Server) capabilities(client *Client) Capabilities {
+	encoding := client.encoding
+	if encoding == "" {
+		encoding = "json"
+	}
+	compression := "identity"
+	if _, ok := client.conn.(*gzipResponseWriter); ok {
+		compression = "gzip"
+	}
+	return Capabilities{
+		Encoding:          encoding,
+		Compression:       compression,
+		HeartbeatInterval: s.config.HeartbeatInterval.Milliseconds(),
+		Retry:             s.config.RetryTimeout,
+	}
+}
@@ -0,0 +1,121 @@
+package sse
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectionEvent returns the data of the connection event in body
+func connectionEvent(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	for _, frame := range strings.Split(body, "\n\n") {
+		if !strings.Contains(frame, "event: connection\n") {
+			continue
+		}
+		for _, line := range strings.Split(frame, "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var decoded map[string]interface{}
+				if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+					t.Fatalf("Connection event data is not JSON: %v", err)
+				}
+				return decoded
+			}
+		}
+	}
+	t.Fatalf("No connection event in %q", body)
+	return nil
+}
+
+func TestAnnounceCapabilities(t *testing.T) {
+	config := DefaultConfig()
+	config.AnnounceCapabilities = true
+	config.Encoders = map[string]Encoder{
+		"compact": EncoderFunc(func(data interface{}) (string, error) {
+			b, err := json.Marshal(data)
+			return string(b), err
+		}),
+	}
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events?encoding=compact", http.NoBody))
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	capabilities, ok := connectionEvent(t, w.Body.String())["capabilities"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected capabilities in the connection event, got %q", w.Body.String())
+	}
+	want := map[string]interface{}{
+		"encoding":              "compact",
+		"compression":           "identity",
+		"heartbeat_interval_ms": float64(config.HeartbeatInterval.Milliseconds()),
+		"retry_ms":              float64(config.RetryTimeout),
+	}
+	for key, value := range want {
+		if capabilities[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, capabilities[key])
+		}
+	}
+}
+
+func TestCapabilitiesNotAnnouncedByDefault(t *testing.T) {
+	server := NewServer()
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	if _, ok := connectionEvent(t, w.Body.String())["capabilities"]; ok {
+		t.Error("Expected no capabilities unless AnnounceCapabilities is set")
+	}
+}
+
+func TestAnnounceCapabilitiesWithCompression(t *testing.T) {
+	config := DefaultConfig()
+	config.AnnounceCapabilities = true
+	config.EnableCompression = true
+	config.Encoders = map[string]Encoder{
+		"compact": EncoderFunc(func(data interface{}) (string, error) {
+			b, err := json.Marshal(data)
+			return string(b), err
+		}),
+	}
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events?encoding=compact", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+	<-done
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip stream: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Decompressing the stream failed: %v", err)
+	}
+
+	capabilities, ok := connectionEvent(t, string(body))["capabilities"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected capabilities in the connection event, got %q", body)
+	}
+	if capabilities["encoding"] != "compact" || capabilities["compression"] != "gzip" {
+		t.Errorf("Expected compact encoding over gzip, got %v", capabilities)
+	}
+}
@@ -0,0 +1,37 @@
+package sse
+
+import "time"
+
+// Checkpoint is the data of a "checkpoint" event
+type Checkpoint struct {
+	Sequence uint64 `json:"sequence"`          // highest sequence number assigned by Config.AutoEventID
+	LastID   string `json:"last_id,omitempty"` // that sequence number rendered as an event ID, empty before the first
+}
+
+// checkpoints broadcasts a checkpoint event every interval so idle clients
+// can confirm they have seen the latest sequence ID
+func (s *Server) checkpoints(interval time.Duration) {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Like heartbeats, checkpoints bypass the throttle and history
+			s.broadcastTo(Event{Type: "checkpoint", Data: s.checkpoint()}, nil, false)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkpoint returns the current sequence position
+func (s *Server) checkpoint() Checkpoint {
+	seq := s.eventSeq.Load()
+	if seq == 0 {
+		return Checkpoint{}
+	}
+	return Checkpoint{Sequence: seq, LastID: s.formatSeq(seq)}
+}
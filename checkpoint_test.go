@@ -0,0 +1,64 @@
+package sse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointEvents(t *testing.T) {
+	config := DefaultConfig()
+	config.AutoEventID = true
+	config.CheckpointInterval = 50 * time.Millisecond
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		server.Broadcast(Event{Type: "update", Data: i})
+	}
+
+	time.Sleep(230 * time.Millisecond)
+	server.Shutdown()
+
+	var checkpoints []Checkpoint
+	for _, frame := range strings.Split(w.Body.String(), "\n\n") {
+		if !strings.Contains(frame, "event: checkpoint\n") {
+			continue
+		}
+		data := frame[strings.Index(frame, "data: ")+len("data: "):]
+		var checkpoint Checkpoint
+		if err := json.Unmarshal([]byte(data), &checkpoint); err != nil {
+			t.Fatalf("Expected checkpoint JSON, got %q: %v", data, err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	// About 250ms at a 50ms cadence, allowing for scheduling jitter
+	if n := len(checkpoints); n < 3 || n > 6 {
+		t.Fatalf("Expected about 5 checkpoints, got %d", n)
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Sequence != 3 || checkpoint.LastID != "3" {
+			t.Errorf("Expected checkpoints at sequence 3, got %+v", checkpoint)
+		}
+	}
+}
+
+func TestCheckpointDisabled(t *testing.T) {
+	server := NewServer()
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if strings.Contains(w.Body.String(), "event: checkpoint") {
+		t.Error("Expected no checkpoints when CheckpointInterval is zero")
+	}
+}
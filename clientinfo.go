@@ -0,0 +1,95 @@
+package sse
+
+import (
+	"sort"
+	"time"
+)
+
+// ClientInfo is a read-only view of a connected client used for routing decisions
+type ClientInfo struct {
+	ID          string            `json:"id"`
+	Identity    string            `json:"identity,omitempty"`
+	Version     string            `json:"version,omitempty"` // advertised in the X-Client-Version header
+	ConnectedAt time.Time         `json:"connected_at"`
+	Attributes  map[string]string `json:"attributes,omitempty"` // shared with the client, must not be modified
+	Types       []string          `json:"types,omitempty"`      // event types subscribed to, see BroadcastToType
+	Rooms       []string          `json:"rooms,omitempty"`      // rooms joined, sorted, see JoinRoom
+}
+
+// info returns a snapshot of the client's routing attributes, subscriptions
+// and rooms. It takes the server's read lock, so callers must not hold s.mu.
+func (c *Client) info() *ClientInfo {
+	info := &ClientInfo{
+		ID:          c.ID,
+		Identity:    c.Identity,
+		Version:     c.Version,
+		ConnectedAt: c.connected,
+		Attributes:  c.Attributes,
+	}
+	if c.server == nil {
+		return info
+	}
+
+	c.server.mu.RLock()
+	defer c.server.mu.RUnlock()
+	if len(c.types) > 0 {
+		info.Types = append([]string(nil), c.types...)
+	}
+	for room := range c.rooms {
+		info.Rooms = append(info.Rooms, room)
+	}
+	sort.Strings(info.Rooms)
+	return info
+}
+
+// BroadcastFunc sends an event to every client for which match returns true
+// and returns how many clients matched. match receives a read-only view of
+// each client and must not block.
+func (s *Server) BroadcastFunc(match func(*ClientInfo) bool, event Event) int {
+	if !s.throttleBroadcast() {
+		return 0
+	}
+	if event.route == "" {
+		event.route = routeFunc
+	}
+
+	matched := 0
+	for _, e := range s.expandEvent(event) {
+		matched = s.broadcastTo(e, func(c *Client) bool {
+			return match(c.info())
+		}, false)
+	}
+	return matched
+}
+
+// ListClients returns a snapshot of the connected clients ordered by ID
+func (s *Server) ListClients() []ClientInfo {
+	clients := s.snapshotClients(false, Event{})
+
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, *client.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// CloseWhere disconnects every client for which match returns true, as
+// CloseClient does, and returns how many were closed. With
+// Config.SendCloseEvent each gets a close event carrying reason, or
+// "closed" when reason is empty. match receives a read-only view of each
+// client and must not block.
+func (s *Server) CloseWhere(match func(*ClientInfo) bool, reason string) int {
+	if reason == "" {
+		reason = CloseReasonClosed
+	}
+
+	closed := 0
+	for _, client := range s.snapshotClients(false, Event{}) {
+		if match(client.info()) {
+			s.disconnectClient(client, reason)
+			closed++
+		}
+	}
+	return closed
+}
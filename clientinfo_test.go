@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcastFunc(t *testing.T) {
+	config := DefaultConfig()
+	config.IdentityFunc = func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}
+	server := NewServerWithConfig(config)
+
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for _, user := range []string{"admin-1", "admin-2", "guest-1"} {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		req.Header.Set("X-User", user)
+		recorders[user] = httptest.NewRecorder()
+		go server.HandleSSE(recorders[user], req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	matched := server.BroadcastFunc(func(c *ClientInfo) bool {
+		return strings.HasPrefix(c.Identity, "admin-") && !c.ConnectedAt.IsZero()
+	}, Event{Type: "admin", Data: "maintenance at noon"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if matched != 2 {
+		t.Errorf("Expected 2 matched clients, got %d", matched)
+	}
+
+	for user, w := range recorders {
+		got := strings.Contains(w.Body.String(), "maintenance at noon")
+		if want := strings.HasPrefix(user, "admin-"); got != want {
+			t.Errorf("Expected %s delivery=%v, got %v", user, want, got)
+		}
+	}
+}
+
+func TestClientInfoTypesAndRooms(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientIDFunc = func(r *http.Request) string {
+		return r.URL.Query().Get("id")
+	}
+	server := NewServerWithConfig(config)
+
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for _, target := range []string{"/events?id=a&types=chat,alert", "/events?id=b&types=chat", "/events?id=c"} {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		id := req.URL.Query().Get("id")
+		recorders[id] = httptest.NewRecorder()
+		go server.HandleSSE(recorders[id], req)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, join := range [][2]string{{"a", "lobby"}, {"a", "admins"}, {"c", "lobby"}} {
+		if err := server.JoinRoom(join[0], join[1]); err != nil {
+			t.Fatalf("Expected %s to join %s, got %v", join[0], join[1], err)
+		}
+	}
+
+	infos := server.ListClients()
+	if len(infos) != 3 {
+		t.Fatalf("Expected 3 clients, got %d", len(infos))
+	}
+	if got := infos[0]; !reflect.DeepEqual(got.Types, []string{"chat", "alert"}) || !reflect.DeepEqual(got.Rooms, []string{"admins", "lobby"}) {
+		t.Errorf("Expected a's types and sorted rooms, got %v and %v", got.Types, got.Rooms)
+	}
+	if got := infos[2]; got.Types != nil || !reflect.DeepEqual(got.Rooms, []string{"lobby"}) {
+		t.Errorf("Expected c with no types in the lobby, got %v and %v", got.Types, got.Rooms)
+	}
+
+	matched := server.BroadcastFunc(func(c *ClientInfo) bool {
+		return slices.Contains(c.Types, "chat") && slices.Contains(c.Rooms, "lobby")
+	}, Event{Type: "chat", Data: "lobby chatter"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if matched != 1 {
+		t.Errorf("Expected 1 matched client, got %d", matched)
+	}
+	for id, w := range recorders {
+		got := strings.Contains(w.Body.String(), "lobby chatter")
+		if want := id == "a"; got != want {
+			t.Errorf("Expected %s delivery=%v, got %v", id, want, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeFrame is the terminal event written for reason
+func closeFrame(reason string) string {
+	return "event: close\ndata: {\"reason\":\"" + reason + "\"}\n\n"
+}
+
+func TestCloseEventOnShutdown(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+	<-done
+
+	if body := w.Body.String(); !strings.HasSuffix(body, closeFrame(CloseReasonShutdown)) {
+		t.Errorf("Expected stream to end with shutdown close event, got %q", body)
+	}
+}
+
+func TestCloseEventOnSlowConsumer(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	config.BufferSize = 2
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w := newStallWriter()
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	w.stall()
+
+	for i := 0; i < 10; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	w.unstall()
+	<-done
+
+	if body := w.Body.String(); !strings.HasSuffix(body, closeFrame(CloseReasonSlowConsumer)) {
+		t.Errorf("Expected stream to end with slow consumer close event, got %q", body)
+	}
+}
+
+func TestCloseEventOnBufferLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	config.MaxTotalBuffered = 5
+	config.BufferPolicy = BufferCloseSlowest
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w := newStallWriter()
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	w.stall()
+
+	for i := 0; i < 10; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	w.unstall()
+	<-done
+
+	if body := w.Body.String(); !strings.HasSuffix(body, closeFrame(CloseReasonBufferLimit)) {
+		t.Errorf("Expected stream to end with buffer limit close event, got %q", body)
+	}
+}
+
+func TestNoCloseEventOnClientDisconnect(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	if body := w.Body.String(); strings.Contains(body, "event: close") {
+		t.Errorf("Expected no close event for a client-initiated disconnect, got %q", body)
+	}
+}
+
+func TestCloseWhere(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	config.AttributesFunc = func(r *http.Request) map[string]string {
+		return map[string]string{"tenant": r.URL.Query().Get("tenant")}
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	type conn struct {
+		tenant string
+		w      *httptest.ResponseRecorder
+		done   chan struct{}
+	}
+	var conns []conn
+	for _, tenant := range []string{"acme", "acme", "globex"} {
+		c := conn{tenant: tenant, w: httptest.NewRecorder(), done: make(chan struct{})}
+		go func() {
+			server.HandleSSE(c.w, httptest.NewRequest("GET", "/events?tenant="+c.tenant, http.NoBody))
+			close(c.done)
+		}()
+		conns = append(conns, c)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	closed := server.CloseWhere(func(c *ClientInfo) bool {
+		return c.Attributes["tenant"] == "acme"
+	}, "tenant_suspended")
+	if closed != 2 {
+		t.Errorf("Expected 2 clients closed, got %d", closed)
+	}
+
+	for _, c := range conns {
+		if c.tenant != "acme" {
+			continue
+		}
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected closed client's handler to return")
+		}
+		if body := c.w.Body.String(); !strings.HasSuffix(body, closeFrame("tenant_suspended")) {
+			t.Errorf("Expected stream to end with the close event, got %q", body)
+		}
+	}
+
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected the other tenant's client to stay connected, got %d connections", count)
+	}
+}
@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipResponseWriter compresses a stream with gzip. Flushes go through the
+// compressor first, so each event reaches the client as soon as it is
+// written. Unwrap lets http.ResponseController reach the connection for
+// write deadlines.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	started bool
+}
+
+// Write compresses p, declaring the content encoding on the first write so
+// errors written to the plain ResponseWriter before then stay uncompressed
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+	}
+	return w.gz.Write(p)
+}
+
+// FlushError pushes compressed bytes out to the client
+func (w *gzipResponseWriter) FlushError() error {
+	if err := w.gz.Flush(); err != nil {
+		return err
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close writes the gzip trailer if the stream was started
+func (w *gzipResponseWriter) close() {
+	if !w.started {
+		return
+	}
+	if err := w.gz.Close(); err == nil {
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// compressStream returns the writer a connection's events go through: a
+// gzip writer over w when Config.EnableCompression is set and the request
+// accepts gzip, or w itself otherwise
+func (s *Server) compressStream(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if !s.config.EnableCompression {
+		return w
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return w
+	}
+	return &gzipResponseWriter{ResponseWriter: w, gz: gzip.NewWriter(w)}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, either
+// by name or by wildcard, with a non-zero quality
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// closeCompression finishes a compressed stream once nothing more will be
+// written to it
+func (c *Client) closeCompression() {
+	if gz, ok := c.conn.(*gzipResponseWriter); ok {
+		gz.close()
+	}
+}
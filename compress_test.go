@@ -0,0 +1,108 @@
+package sse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressedStreamFlushesEachEvent(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableCompression = true
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSSE))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	// Keep the transport from decompressing so the raw encoding is visible
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+	if got := resp.Header.Get("Vary"); !strings.Contains(got, "Accept-Encoding") {
+		t.Errorf("Expected Vary to include Accept-Encoding, got %q", got)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip stream: %v", err)
+	}
+	lines := bufio.NewScanner(gz)
+
+	// The stream stays open, so each event must be readable as soon as it is flushed
+	readUntil := func(want string) {
+		t.Helper()
+		found := make(chan bool, 1)
+		go func() {
+			for lines.Scan() {
+				if lines.Text() == want {
+					found <- true
+					return
+				}
+			}
+			found <- false
+		}()
+		select {
+		case ok := <-found:
+			if !ok {
+				t.Fatalf("Stream ended before %q", want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+
+	readUntil("event: connection")
+	server.Broadcast(Event{Type: "tick", Data: "compressed"})
+	readUntil("data: compressed")
+}
+
+func TestCompressionRequiresOptInAndAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		acceptEncoding string
+	}{
+		{"disabled", false, "gzip"},
+		{"not accepted", true, "br"},
+		{"refused with q=0", true, "gzip;q=0, identity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.EnableCompression = tt.enabled
+			server := NewServerWithConfig(config)
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			go server.HandleSSE(w, req)
+			time.Sleep(50 * time.Millisecond)
+			server.Shutdown()
+
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Expected an uncompressed stream, got Content-Encoding %q", got)
+			}
+			if !strings.Contains(w.Body.String(), "event: connection") {
+				t.Errorf("Expected a plain text stream, got %q", w.Body.String())
+			}
+		})
+	}
+}
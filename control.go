@@ -0,0 +1,50 @@
+package sse
+
+import (
+	"io"
+	"net/http"
+)
+
+// maxControlBodyBytes caps the size of a control message body
+const maxControlBodyBytes = 1 << 20
+
+// ControlHandler returns an HTTP handler for control messages sent by
+// clients alongside their event stream. The client ID is read from the
+// X-SSE-Client-ID header or the client_id query parameter and must belong
+// to a connected client, otherwise the request is answered with 404. The
+// request body is passed to handler; a handler error is answered with 400
+// and success with 204.
+func (s *Server) ControlHandler(handler func(clientID string, body []byte) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clientID := r.Header.Get("X-SSE-Client-ID")
+		if clientID == "" {
+			clientID = r.URL.Query().Get("client_id")
+		}
+
+		s.mu.RLock()
+		_, connected := s.clients[clientID]
+		s.mu.RUnlock()
+		if clientID == "" || !connected {
+			http.Error(w, ErrClientNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxControlBodyBytes))
+		if err != nil {
+			http.Error(w, "Control message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if err := handler(clientID, body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,64 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestControlHandler(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+	addBareClient(server, "live", 1)
+
+	var gotID, gotBody string
+	handler := server.ControlHandler(func(clientID string, body []byte) error {
+		gotID, gotBody = clientID, string(body)
+		if gotBody == "bad" {
+			return errors.New("unknown command")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		header   string
+		body     string
+		wantCode int
+		wantID   string
+	}{
+		{"header id", "POST", "/control", "live", "pause", http.StatusNoContent, "live"},
+		{"query id", "POST", "/control?client_id=live", "", "resume", http.StatusNoContent, "live"},
+		{"unknown client", "POST", "/control", "ghost", "pause", http.StatusNotFound, ""},
+		{"missing id", "POST", "/control", "", "pause", http.StatusNotFound, ""},
+		{"handler error", "POST", "/control", "live", "bad", http.StatusBadRequest, "live"},
+		{"wrong method", "GET", "/control", "live", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID, gotBody = "", ""
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("X-SSE-Client-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if gotID != tt.wantID {
+				t.Errorf("Expected handler called with ID %q, got %q", tt.wantID, gotID)
+			}
+			if tt.wantID != "" && gotBody != tt.body {
+				t.Errorf("Expected handler body %q, got %q", tt.body, gotBody)
+			}
+		})
+	}
+}
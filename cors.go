@@ -0,0 +1,63 @@
+package sse
+
+import "net/http"
+
+// originSet is an immutable CORS allowlist; a nil set allows any origin
+type originSet map[string]bool
+
+// newOriginSet builds an allowlist from origins, returning nil when the list
+// is empty or contains "*"
+func newOriginSet(origins []string) originSet {
+	if len(origins) == 0 {
+		return nil
+	}
+
+	set := make(originSet, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			return nil
+		}
+		set[origin] = true
+	}
+	return set
+}
+
+// SetAllowedOrigins replaces the CORS allowlist without a restart. New
+// connections are checked against the latest list; an empty list or "*"
+// allows any origin. Established connections are not affected.
+func (s *Server) SetAllowedOrigins(origins []string) {
+	set := newOriginSet(origins)
+	s.origins.Store(&set)
+}
+
+// applyCORS sets the CORS headers for r and reports whether its origin is
+// allowed. Requests without an Origin header are always allowed. Origins on
+// an explicit allowlist may send credentials.
+func (s *Server) applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+
+	var allowed originSet
+	if set := s.origins.Load(); set != nil {
+		allowed = *set
+	}
+	if allowed == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+
+	// The response now depends on the request's origin
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if !allowed[origin] {
+		return false
+	}
+	// EventSource withCredentials needs the exact origin plus this header;
+	// browsers refuse credentials with the "*" wildcard
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	return true
+}
@@ -0,0 +1,225 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// corsDecision opens a connection from origin and returns the allowed origin
+// header, or "" when the connection was refused
+func corsDecision(server *Server, origin string) string {
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	req.Header.Set("Origin", origin)
+	w := httptest.NewRecorder()
+
+	// A refused origin returns before the stream starts; an allowed one is
+	// closed straight away by canceling its request
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	server.HandleSSE(w, req.WithContext(ctx))
+
+	if w.Code == http.StatusForbidden {
+		return ""
+	}
+	return w.Header().Get("Access-Control-Allow-Origin")
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowedOrigins = []string{"https://a.example"}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    string
+	}{
+		{"configured origin", nil, "https://a.example", "https://a.example"},
+		{"unknown origin", nil, "https://b.example", ""},
+		{"added origin", []string{"https://a.example", "https://b.example"}, "https://b.example", "https://b.example"},
+		{"removed origin", []string{"https://b.example"}, "https://a.example", ""},
+		{"wildcard", []string{"*"}, "https://c.example", "*"},
+		{"empty list", []string{}, "https://c.example", "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.origins != nil {
+				server.SetAllowedOrigins(tt.origins)
+			}
+			if got := corsDecision(server, tt.origin); got != tt.want {
+				t.Errorf("Expected allowed origin %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetAllowedOriginsConcurrent(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	lists := [][]string{{"https://a.example"}, {"https://b.example"}}
+	server.SetAllowedOrigins(lists[0])
+
+	stop := make(chan struct{})
+	mutated := make(chan struct{})
+	go func() {
+		defer close(mutated)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				server.SetAllowedOrigins(lists[i%2])
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(origin string) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				// Either list may be current, but a decision must match one of them
+				if got := corsDecision(server, origin); got != "" && got != origin {
+					t.Errorf("Expected %q or a refusal, got %q", origin, got)
+				}
+			}
+		}(fmt.Sprintf("https://%c.example", 'a'+i%2))
+	}
+
+	wg.Wait()
+	close(stop)
+	<-mutated
+
+	// Once mutation settles, decisions reflect the current list exactly
+	server.SetAllowedOrigins(lists[1])
+	if got := corsDecision(server, "https://a.example"); got != "" {
+		t.Errorf("Expected removed origin to be refused, got %q", got)
+	}
+	if got := corsDecision(server, "https://b.example"); got != "https://b.example" {
+		t.Errorf("Expected current origin to be allowed, got %q", got)
+	}
+}
+
+func TestCredentialedCORS(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowedOrigins = []string{"https://app.example"}
+	config.IdentityFunc = func(r *http.Request) string {
+		if cookie, err := r.Cookie("session"); err == nil {
+			return cookie.Value
+		}
+		return ""
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+	req.Header.Set("Origin", "https://app.example")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "user-42"})
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ids := server.ClientsByIdentity("user-42")
+	cancel()
+	<-done
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Expected the request origin to be reflected, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+	if len(ids) != 1 {
+		t.Errorf("Expected the identity hook to see the session cookie, got clients %v", ids)
+	}
+}
+
+func TestWildcardCORSWithoutCredentials(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	req.Header.Set("Origin", "https://any.example")
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	w := httptest.NewRecorder()
+	server.HandleSSE(w, req.WithContext(ctx))
+
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header with a wildcard origin, got %q", got)
+	}
+}
+
+func TestCredentialedCORSAuthorize(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowedOrigins = []string{"https://app.example"}
+	config.Authorize = func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "valid-token" {
+			return "", false
+		}
+		return "session-client", true
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name     string
+		cookie   string
+		wantCode int
+	}{
+		{"accepted", "valid-token", http.StatusOK},
+		{"refused", "stolen-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+			req.Header.Set("Origin", "https://app.example")
+			req.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				server.HandleSSE(w, req)
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+
+			connected := server.GetConnectionCount()
+			cancel()
+			<-done
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Expected credentials to be allowed, got %q", got)
+			}
+			if want := tt.wantCode == http.StatusOK; (connected == 1) != want {
+				t.Errorf("Expected connected=%v, got %d connections", want, connected)
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(w.Body.String(), "session-client") {
+				t.Errorf("Expected the client ID chosen by Authorize, got %q", w.Body.String())
+			}
+		})
+	}
+}
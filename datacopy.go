@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// snapshotData returns a deep copy of broadcast data, so callers may mutate
+// or reuse it as soon as the broadcast returns without racing the client
+// loops that encode it later. Maps, slices, arrays, pointers, interfaces and
+// the exported fields of structs are copied with their types intact;
+// unexported fields, channels and functions are shared.
+func snapshotData(data interface{}) interface{} {
+	switch v := data.(type) {
+	case nil, string, bool, int, int64, float64:
+		return data
+	case []byte:
+		return bytes.Clone(v)
+	}
+
+	c := dataCopier{seen: make(map[copyKey]reflect.Value)}
+	return c.copy(reflect.ValueOf(data)).Interface()
+}
+
+// dataCopier deep-copies values, remembering copied pointers and maps so
+// shared references stay shared and cycles terminate
+type dataCopier struct {
+	seen map[copyKey]reflect.Value
+}
+
+// copyKey identifies a copied pointer or map. The type is part of the key
+// because different types can share an address, like a struct and its
+// first field, or two zero-size values.
+type copyKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+// copy returns a deep copy of v
+func (c dataCopier) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		return c.copyPointer(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		dup := reflect.New(v.Type()).Elem()
+		dup.Set(c.copy(v.Elem()))
+		return dup
+	case reflect.Map:
+		return c.copyMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		dup := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		c.copyElems(dup, v)
+		return dup
+	case reflect.Array:
+		dup := reflect.New(v.Type()).Elem()
+		c.copyElems(dup, v)
+		return dup
+	case reflect.Struct:
+		dup := reflect.New(v.Type()).Elem()
+		dup.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := dup.Field(i); field.CanSet() {
+				field.Set(c.copy(v.Field(i)))
+			}
+		}
+		return dup
+	default:
+		return v
+	}
+}
+
+// copyPointer copies the value v points to, reusing an earlier copy
+func (c dataCopier) copyPointer(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return v
+	}
+	key := copyKey{v.Type(), v.Pointer()}
+	if dup, ok := c.seen[key]; ok {
+		return dup
+	}
+	dup := reflect.New(v.Type().Elem())
+	c.seen[key] = dup
+	dup.Elem().Set(c.copy(v.Elem()))
+	return dup
+}
+
+// copyMap copies a map's values, keeping keys as they are
+func (c dataCopier) copyMap(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return v
+	}
+	key := copyKey{v.Type(), v.Pointer()}
+	if dup, ok := c.seen[key]; ok {
+		return dup
+	}
+	dup := reflect.MakeMapWithSize(v.Type(), v.Len())
+	c.seen[key] = dup
+	iter := v.MapRange()
+	for iter.Next() {
+		dup.SetMapIndex(iter.Key(), c.copy(iter.Value()))
+	}
+	return dup
+}
+
+// copyElems deep-copies the elements of src into dst, which has the same length
+func (c dataCopier) copyElems(dst, src reflect.Value) {
+	if isFlatKind(src.Type().Elem().Kind()) {
+		reflect.Copy(dst, src)
+		return
+	}
+	for i := 0; i < src.Len(); i++ {
+		dst.Index(i).Set(c.copy(src.Index(i)))
+	}
+}
+
+// isFlatKind reports whether values of kind hold no references to copy
+func isFlatKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
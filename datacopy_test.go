@@ -0,0 +1,130 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type copyNode struct {
+	Name     string
+	Tags     []string
+	Next     *copyNode
+	Extra    interface{}
+	internal []int
+}
+
+func TestSnapshotData(t *testing.T) {
+	loop := &copyNode{Name: "loop"}
+	loop.Next = loop
+
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{"map", map[string]interface{}{"a": []int{1, 2}}},
+		{"bytes", []byte("abc")},
+		{"struct pointer", &copyNode{Name: "n", Tags: []string{"x"}, Extra: map[string]int{"k": 1}}},
+		{"cycle", loop},
+		{"array", [2][]string{{"a"}, {"b"}}},
+		{"nil", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup := snapshotData(tt.data)
+			if !reflect.DeepEqual(dup, tt.data) {
+				t.Errorf("Expected an equal copy, got %#v", dup)
+			}
+			if tt.data != nil && reflect.TypeOf(dup) != reflect.TypeOf(tt.data) {
+				t.Errorf("Expected type %T to be kept, got %T", tt.data, dup)
+			}
+		})
+	}
+
+	original := &copyNode{Tags: []string{"x"}, Extra: map[string]int{"k": 1}, internal: []int{1}}
+	dup := snapshotData(original).(*copyNode)
+	original.Tags[0] = "changed"
+	original.Extra.(map[string]int)["k"] = 2
+	if dup == original || dup.Tags[0] != "x" || dup.Extra.(map[string]int)["k"] != 1 {
+		t.Errorf("Expected the copy to be independent of the original, got %+v", dup)
+	}
+	if &dup.internal[0] != &original.internal[0] {
+		t.Error("Expected unexported fields to be shared")
+	}
+
+	if dup := snapshotData(loop).(*copyNode); dup == loop || dup.Next != dup {
+		t.Error("Expected the cycle to be copied as a cycle")
+	}
+}
+
+type copyCounter struct {
+	Count int
+	Label string
+}
+
+func TestSnapshotDataAliasedPointers(t *testing.T) {
+	type empty struct{}
+	type blank [0]int
+	type aliases struct {
+		Counter *copyCounter
+		Count   *int
+	}
+	type zeros struct {
+		Empty *empty
+		Blank *blank
+	}
+
+	counter := &copyCounter{Count: 1, Label: "c"}
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{"field alias", aliases{counter, &counter.Count}},
+		{"interface field alias", []interface{}{&counter.Count, counter}},
+		{"zero size", zeros{new(empty), new(blank)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup := snapshotData(tt.data)
+			if !reflect.DeepEqual(dup, tt.data) {
+				t.Errorf("Expected an equal copy, got %#v", dup)
+			}
+		})
+	}
+}
+
+func TestBroadcastThenMutate(t *testing.T) {
+	server := NewServer()
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+
+	data := map[string]interface{}{"status": "original", "items": []string{"a"}}
+	for i := 0; i < 20; i++ {
+		server.Broadcast(Event{Type: "update", Data: data})
+		// Mutating right away must neither race nor change what clients see
+		data["status"] = "mutated"
+		data["items"].([]string)[0] = "b"
+		data["status"] = "original"
+		data["items"].([]string)[0] = "a"
+	}
+	server.Broadcast(Event{Type: "update", Data: data})
+	data["status"] = "mutated"
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	if strings.Contains(body, "mutated") || strings.Contains(body, `"b"`) {
+		t.Errorf("Expected clients to see the data as broadcast, got %q", body)
+	}
+	if count := strings.Count(body, `"status":"original"`); count != 21 {
+		t.Errorf("Expected 21 original snapshots, got %d", count)
+	}
+}
@@ -0,0 +1,178 @@
+package sse
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BroadcastResult reports the outcome of BroadcastDeadline or BroadcastTracked
+type BroadcastResult struct {
+	Delivered []string // IDs of clients the event was queued for, or written to when tracked
+	Dropped   []string // IDs of clients that lost a tracked event to a full buffer or failed write
+	TimedOut  []string // IDs of clients whose buffer stayed full, or that had not processed a tracked event, past the timeout
+}
+
+// maxDeadlineWaiters bounds the goroutines BroadcastDeadline uses to wait on
+// full clients
+const maxDeadlineWaiters = 256
+
+// BroadcastDeadline sends an event to all clients like Broadcast, waiting up
+// to perClientTimeout for room in each client's buffer. Unlike Broadcast,
+// slow clients are not disconnected; they are reported in the result
+// instead. Clients with room get the event straight away, so a full client
+// never delays the others. Full clients are waited on concurrently, by at
+// most maxDeadlineWaiters goroutines, until a shared deadline, so the call
+// takes at most about perClientTimeout. The event is sent as is, even with
+// Config.SplitSliceData set.
+func (s *Server) BroadcastDeadline(event Event, perClientTimeout time.Duration) BroadcastResult {
+	if !s.throttleBroadcast() {
+		return BroadcastResult{}
+	}
+
+	waiter := &deadlineWaiter{
+		server:   s,
+		deadline: time.Now().Add(perClientTimeout),
+		slots:    make(chan struct{}, maxDeadlineWaiters),
+		full:     make(map[string]bool),
+	}
+	event.waiter = waiter
+	s.broadcastTo(event, func(c *Client) bool {
+		if c.isClosed() {
+			return false
+		}
+		waiter.expect(c.ID)
+		return true
+	}, true)
+
+	return waiter.finish()
+}
+
+// deadlineWaiter collects the outcome of a BroadcastDeadline. Fan-out hands
+// it the clients whose buffers were full instead of applying
+// Config.OverflowPolicy, and it waits on them in the background.
+type deadlineWaiter struct {
+	server   *Server
+	deadline time.Time
+	slots    chan struct{} // bounds the goroutines waiting on full clients
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	matched  []string        // clients the event was queued or waited for
+	full     map[string]bool // clients among matched that were full at fan-out
+	result   BroadcastResult
+}
+
+// expect adds a client the event is about to be queued for
+func (w *deadlineWaiter) expect(clientID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.matched = append(w.matched, clientID)
+}
+
+// wait starts waiting on clients whose buffers were full without blocking
+// fan-out, which has already queued event for every client with room
+func (w *deadlineWaiter) wait(full []*Client, event Event) {
+	if len(full) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	for _, client := range full {
+		w.full[client.ID] = true
+	}
+	w.mu.Unlock()
+
+	w.wg.Add(len(full))
+	go func() {
+		for _, client := range full {
+			w.slots <- struct{}{}
+			go func(c *Client) {
+				defer func() {
+					<-w.slots
+					w.wg.Done()
+				}()
+
+				sent, closed := c.enqueueWithin(event, time.Until(w.deadline))
+				if !closed {
+					w.record(c.ID, sent)
+				}
+			}(client)
+		}
+	}()
+}
+
+// record notes whether the event reached a client that was full at fan-out
+func (w *deadlineWaiter) record(clientID string, sent bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sent {
+		w.result.Delivered = append(w.result.Delivered, clientID)
+	} else {
+		w.server.eventsDropped.Add(1)
+		w.result.TimedOut = append(w.result.TimedOut, clientID)
+	}
+}
+
+// finish waits for every full client to get room or time out and returns
+// the result. Matched clients that were not full had the event queued
+// during fan-out.
+func (w *deadlineWaiter) finish() BroadcastResult {
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := w.result
+	for _, clientID := range w.matched {
+		if !w.full[clientID] {
+			result.Delivered = append(result.Delivered, clientID)
+		}
+	}
+	sort.Strings(result.Delivered)
+	sort.Strings(result.TimedOut)
+	return result
+}
+
+// enqueueWithin waits up to timeout for room in the client's queue,
+// queueing at once when there is room. The wait ends as soon as the client
+// starts closing, so a waiting broadcast never holds up close for long. It
+// reports whether the event was queued, and whether the client was closed.
+func (c *Client) enqueueWithin(event Event, timeout time.Duration) (sent, closed bool) {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.closed {
+		return false, true
+	}
+
+	queue := c.queueFor(event)
+	select {
+	case queue <- event:
+		c.lanes.signal()
+		return true, false
+	default:
+	}
+	if timeout <= 0 {
+		return false, false
+	}
+
+	closing := c.closedSignal()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- event:
+		c.lanes.signal()
+		return true, false
+	case <-closing:
+		return false, true
+	case <-timer.C:
+		return false, false
+	}
+}
+
+// isClosed reports whether the client has been closed
+func (c *Client) isClosed() bool {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	return c.closed
+}
@@ -0,0 +1,193 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBroadcastDeadline(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	addBareClient(server, "fast", 10)
+	slow := addBareClient(server, "slow", 1)
+	slow.EventCh <- Event{Type: "backlog"}
+
+	start := time.Now()
+	result := server.BroadcastDeadline(Event{Type: "tick", Data: 1}, 50*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if len(result.Delivered) != 1 || result.Delivered[0] != "fast" {
+		t.Errorf("Expected only fast client delivered, got %v", result.Delivered)
+	}
+	if len(result.TimedOut) != 1 || result.TimedOut[0] != "slow" {
+		t.Errorf("Expected slow client timed out, got %v", result.TimedOut)
+	}
+	if elapsed > 200*time.Millisecond {
+		t.Errorf("Expected deadline to bound the call, took %v", elapsed)
+	}
+
+	// Timed out clients are reported, not dropped
+	if count := server.GetConnectionCount(); count != 2 {
+		t.Errorf("Expected both clients to stay connected, got %d", count)
+	}
+}
+
+func TestBroadcastDeadlineBlockedClientDoesNotDelayOthers(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	blocked := addBareClient(server, "blocked", 1)
+	blocked.EventCh <- Event{Type: "backlog"}
+	others := make([]*Client, 20)
+	for i := range others {
+		others[i] = addBareClient(server, fmt.Sprintf("client-%d", i), 1)
+	}
+
+	done := make(chan BroadcastResult)
+	go func() {
+		done <- server.BroadcastDeadline(Event{Type: "tick"}, 500*time.Millisecond)
+	}()
+
+	// The others have the event while the call is still waiting on the blocked client
+	time.Sleep(50 * time.Millisecond)
+	for _, client := range others {
+		if len(client.EventCh) != 1 {
+			t.Errorf("Expected %s to receive the event promptly", client.ID)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("Expected the call to still be waiting on the blocked client")
+	default:
+	}
+
+	result := <-done
+	if len(result.Delivered) != len(others) || len(result.TimedOut) != 1 {
+		t.Errorf("Expected %d delivered and 1 timed out, got %+v", len(others), result)
+	}
+}
+
+func TestBroadcastDeadlineWaitsForRoom(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "draining", 1)
+	client.EventCh <- Event{Type: "backlog"}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-client.EventCh
+	}()
+
+	result := server.BroadcastDeadline(Event{Type: "tick"}, 200*time.Millisecond)
+	if len(result.Delivered) != 1 {
+		t.Errorf("Expected event to be queued once room freed up, got %+v", result)
+	}
+}
+
+func TestBroadcastDeadlineDoesNotBlockClose(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "full", 1)
+	client.EventCh <- Event{Type: "backlog"}
+
+	done := make(chan BroadcastResult)
+	go func() {
+		done <- server.BroadcastDeadline(Event{Type: "tick"}, 2*time.Second)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		client.close("closed")
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected close not to wait for the pending broadcast")
+	}
+
+	select {
+	case result := <-done:
+		if len(result.Delivered)+len(result.TimedOut) != 0 {
+			t.Errorf("Expected closed client to be left out, got %+v", result)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected the broadcast to stop waiting once the client closed")
+	}
+}
+
+func TestBroadcastDeadlineManyFullClientsDoNotDelayOthers(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	for i := 0; i < maxDeadlineWaiters+50; i++ {
+		full := addBareClient(server, fmt.Sprintf("full-%d", i), 1)
+		full.EventCh <- Event{Type: "backlog"}
+	}
+	ready := make([]*Client, 50)
+	for i := range ready {
+		ready[i] = addBareClient(server, fmt.Sprintf("ready-%d", i), 1)
+	}
+
+	done := make(chan BroadcastResult)
+	go func() {
+		done <- server.BroadcastDeadline(Event{Type: "tick"}, 300*time.Millisecond)
+	}()
+
+	// Every waiter slot is taken, yet ready clients still get the event at once
+	time.Sleep(50 * time.Millisecond)
+	for _, client := range ready {
+		if len(client.EventCh) != 1 {
+			t.Errorf("Expected %s to receive the event promptly", client.ID)
+		}
+	}
+
+	result := <-done
+	if len(result.Delivered) != len(ready) || len(result.TimedOut) != maxDeadlineWaiters+50 {
+		t.Errorf("Expected %d delivered and %d timed out, got %d and %d",
+			len(ready), maxDeadlineWaiters+50, len(result.Delivered), len(result.TimedOut))
+	}
+}
+
+func TestBroadcastDeadlinePublishesLikeBroadcast(t *testing.T) {
+	config := DefaultConfig()
+	config.AutoEventID = true
+	config.HistorySize = 10
+	config.EventMiddleware = func(ctx context.Context, event Event) Event {
+		event.Meta = map[string]string{"via": "middleware"}
+		return event
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	events, unsubscribe := server.Subscribe(context.Background(), "tick")
+	defer unsubscribe()
+	client := addBareClient(server, "client", 1)
+
+	result := server.BroadcastDeadline(Event{Type: "tick"}, 50*time.Millisecond)
+	if len(result.Delivered) != 1 {
+		t.Fatalf("Expected the client to be delivered, got %+v", result)
+	}
+
+	queued := <-client.EventCh
+	if queued.ID != "1" || queued.Meta["via"] != "middleware" {
+		t.Errorf("Expected an assigned ID and middleware meta, got %+v", queued)
+	}
+	if last := server.history.lastID(); last != "1" {
+		t.Errorf("Expected history to record the event ID, got %q", last)
+	}
+	select {
+	case published := <-events:
+		if published.ID != "1" {
+			t.Errorf("Expected subscribers to see the assigned ID, got %q", published.ID)
+		}
+	default:
+		t.Error("Expected in-process subscribers to receive the event")
+	}
+}
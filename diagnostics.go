@@ -0,0 +1,41 @@
+package sse
+
+import "errors"
+
+var (
+	// ErrClientNotFound is returned when an operation targets an unknown client ID
+	ErrClientNotFound = errors.New("sse: client not found")
+	// ErrClientBufferFull is returned when a targeted event cannot be queued
+	ErrClientBufferFull = errors.New("sse: client buffer full")
+)
+
+// Diagnostics is the payload of the diagnostics event
+type Diagnostics struct {
+	Stats   Stats        `json:"stats"`
+	Clients []ClientInfo `json:"clients"`
+}
+
+// SendDiagnostics sends a one-shot "diagnostics" event with the current
+// Stats and ListClients to a single client, typically an admin console.
+// Callers are responsible for checking that the client is authorized.
+func (s *Server) SendDiagnostics(clientID string) error {
+	s.mu.RLock()
+	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	event := Event{
+		Type: "diagnostics",
+		Data: Diagnostics{
+			Stats:   s.Stats(),
+			Clients: s.ListClients(),
+		},
+	}
+	if !client.enqueue(event) {
+		return ErrClientBufferFull
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendDiagnostics(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientIDFunc = func(r *http.Request) string {
+		return r.URL.Query().Get("id")
+	}
+	server := NewServerWithConfig(config)
+
+	ids := []string{"admin", "viewer"}
+	writers := make(map[string]*httptest.ResponseRecorder)
+	for _, id := range ids {
+		writers[id] = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/events?id="+id, http.NoBody)
+		go server.HandleSSE(writers[id], req)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := server.SendDiagnostics("admin"); err != nil {
+		t.Fatalf("SendDiagnostics failed: %v", err)
+	}
+	if err := server.SendDiagnostics("missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound for unknown client, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if body := writers["viewer"].Body.String(); strings.Contains(body, "event: diagnostics\n") {
+		t.Errorf("Expected only the requesting client to receive diagnostics, got %q", body)
+	}
+
+	body := writers["admin"].Body.String()
+	start := strings.Index(body, "event: diagnostics\ndata: ")
+	if start < 0 {
+		t.Fatalf("Expected diagnostics event, got %q", body)
+	}
+	line := body[start+len("event: diagnostics\ndata: "):]
+	line = line[:strings.Index(line, "\n")]
+
+	var diag Diagnostics
+	if err := json.Unmarshal([]byte(line), &diag); err != nil {
+		t.Fatalf("Failed to decode diagnostics %q: %v", line, err)
+	}
+	if diag.Stats.Connections != 2 {
+		t.Errorf("Expected 2 connections in stats, got %d", diag.Stats.Connections)
+	}
+	if len(diag.Clients) != 2 || diag.Clients[0].ID != "admin" || diag.Clients[1].ID != "viewer" {
+		t.Errorf("Expected clients [admin viewer], got %+v", diag.Clients)
+	}
+	for _, client := range diag.Clients {
+		if client.ConnectedAt.IsZero() {
+			t.Errorf("Expected connected_at for client %s", client.ID)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package sse
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Encoder renders event data into the string sent on the `data:` field
+type Encoder interface {
+	Encode(data interface{}) (string, error)
+}
+
+// EncoderFunc adapts a plain function to the Encoder interface
+type EncoderFunc func(data interface{}) (string, error)
+
+// Encode calls f(data)
+func (f EncoderFunc) Encode(data interface{}) (string, error) {
+	return f(data)
+}
+
+// negotiateEncoder picks the encoder for a connection and returns it with
+// its Config.Encoders key. The `encoding` query parameter wins; otherwise
+// each Accept media type is matched against the keys by full type
+// (text/html) or subtype (html). A nil encoder means the default JSON
+// encoding.
+func (s *Server) negotiateEncoder(r *http.Request) (string, Encoder, error) {
+	if len(s.config.Encoders) == 0 {
+		return "", nil, nil
+	}
+
+	if name := r.URL.Query().Get("encoding"); name != "" {
+		enc, ok := s.config.Encoders[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported encoding %q", name)
+		}
+		return name, enc, nil
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if enc, ok := s.config.Encoders[mediaType]; ok {
+			return mediaType, enc, nil
+		}
+		if i := strings.Index(mediaType, "/"); i >= 0 {
+			if enc, ok := s.config.Encoders[mediaType[i+1:]]; ok {
+				return mediaType[i+1:], enc, nil
+			}
+		}
+	}
+
+	return "", nil, nil
+}
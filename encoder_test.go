@@ -0,0 +1,73 @@
+package sse
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiatedEncoders(t *testing.T) {
+	config := DefaultConfig()
+	config.Encoders = map[string]Encoder{
+		"html": EncoderFunc(func(data interface{}) (string, error) {
+			return "<p>" + html.EscapeString(fmt.Sprint(data)) + "</p>", nil
+		}),
+		"plain": EncoderFunc(func(data interface{}) (string, error) {
+			return fmt.Sprint(data), nil
+		}),
+	}
+	server := NewServerWithConfig(config)
+
+	htmlReq := httptest.NewRequest("GET", "/events?encoding=html", http.NoBody)
+	htmlW := httptest.NewRecorder()
+
+	plainReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	plainReq.Header.Set("Accept", "text/plain, text/event-stream")
+	plainW := httptest.NewRecorder()
+
+	defaultReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	defaultW := httptest.NewRecorder()
+
+	go server.HandleSSE(htmlW, htmlReq)
+	go server.HandleSSE(plainW, plainReq)
+	go server.HandleSSE(defaultW, defaultReq)
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.Broadcast(Event{Type: "update", Data: []string{"a", "b"}})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if body := htmlW.Body.String(); !strings.Contains(body, "data: <p>[a b]</p>") {
+		t.Errorf("Expected HTML encoding, got %q", body)
+	}
+	if body := plainW.Body.String(); !strings.Contains(body, "data: [a b]\n") {
+		t.Errorf("Expected plain encoding, got %q", body)
+	}
+	if body := defaultW.Body.String(); !strings.Contains(body, `data: ["a","b"]`) {
+		t.Errorf("Expected default JSON encoding, got %q", body)
+	}
+}
+
+func TestUnsupportedEncoding(t *testing.T) {
+	config := DefaultConfig()
+	config.Encoders = map[string]Encoder{
+		"html": EncoderFunc(func(data interface{}) (string, error) { return "", nil }),
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	req := httptest.NewRequest("GET", "/events?encoding=xml", http.NoBody)
+	w := httptest.NewRecorder()
+
+	server.HandleSSE(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status 406, got %d", w.Code)
+	}
+}
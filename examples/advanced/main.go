@@ -44,24 +44,34 @@ type ChatMessage struct {
 type Server struct {
 	sseServer *sse.Server
 	users     map[string]*User
-	rooms     map[string][]string // room -> user IDs
 	mu        sync.RWMutex
 }
 
 // NewServer creates a new server instance
 func NewServer() *Server {
+	s := &Server{
+		users: make(map[string]*User),
+	}
+
 	config := sse.Config{
 		MaxConnections:    1000,
 		RetryTimeout:      3000,
 		HeartbeatInterval: 30 * time.Second,
 		BufferSize:        1024,
+		// Tag each connection with the user set by AuthMiddleware
+		IdentityFunc: func(r *http.Request) string {
+			userID, _ := r.Context().Value(userIDKey).(string)
+			return userID
+		},
+		// Every connection starts in the default room; the library drops
+		// it from all rooms when it disconnects
+		OnConnect: func(info *sse.ClientInfo) {
+			_ = s.sseServer.JoinRoom(info.ID, "general")
+		},
 	}
 
-	return &Server{
-		sseServer: sse.NewServerWithConfig(config),
-		users:     make(map[string]*User),
-		rooms:     make(map[string][]string),
-	}
+	s.sseServer = sse.NewServerWithConfig(config)
+	return s
 }
 
 // AuthMiddleware checks for valid authentication
@@ -109,28 +119,44 @@ func (s *Server) HandleSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add user to default room
-	s.mu.Lock()
-	if s.rooms["general"] == nil {
-		s.rooms["general"] = []string{}
-	}
-	s.rooms["general"] = append(s.rooms["general"], userID)
-	s.mu.Unlock()
-
 	// Handle SSE connection
 	s.sseServer.HandleSSE(w, r)
+}
 
-	// Remove user from room when connection closes
-	s.mu.Lock()
-	if roomUsers, exists := s.rooms["general"]; exists {
-		for i, id := range roomUsers {
-			if id == userID {
-				s.rooms["general"] = append(roomUsers[:i], roomUsers[i+1:]...)
-				break
-			}
+// HandleJoinRoom moves one of the user's connections into a room. The
+// client ID is the client_id of the connection event.
+func (s *Server) HandleJoinRoom(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		ClientID string `json:"client_id"`
+		Room     string `json:"room"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Room == "" {
+		http.Error(w, "Room is required", http.StatusBadRequest)
+		return
+	}
+
+	// Only let users move their own connections
+	owned := false
+	for _, id := range s.sseServer.ClientsByIdentity(userID) {
+		if id == req.ClientID {
+			owned = true
+			break
 		}
 	}
-	s.mu.Unlock()
+	if !owned || s.sseServer.JoinRoom(req.ClientID, req.Room) != nil {
+		http.Error(w, "Connection not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // HandleLogin handles user login
@@ -234,8 +260,8 @@ func (s *Server) HandleMessage(w http.ResponseWriter, r *http.Request) {
 		Room:      msg.Room,
 	}
 
-	// Broadcast message to room
-	s.sseServer.BroadcastToType("chat_"+msg.Room, sse.Event{
+	// Broadcast message to the room's members
+	s.sseServer.BroadcastToRoom(msg.Room, sse.Event{
 		Type: "chat_message",
 		Data: chatMsg,
 	})
@@ -268,9 +294,10 @@ func (s *Server) StartStatsBroadcaster() {
 	for range ticker.C {
 		s.mu.RLock()
 		userCount := len(s.users)
-		roomCount := len(s.rooms)
 		s.mu.RUnlock()
 
+		roomCount := len(s.sseServer.Rooms())
+
 		connectionCount := s.sseServer.GetConnectionCount()
 
 		s.sseServer.Broadcast(sse.Event{
@@ -312,6 +339,7 @@ func main() {
 	// Protected routes
 	r.HandleFunc("/events", server.AuthMiddleware(server.HandleSSE))
 	r.HandleFunc("/message", server.AuthMiddleware(server.HandleMessage)).Methods("POST")
+	r.HandleFunc("/rooms/join", server.AuthMiddleware(server.HandleJoinRoom)).Methods("POST")
 
 	// Serve static files
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("static")))
@@ -0,0 +1,212 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what a broadcast does for a client whose buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropClient disconnects the client as a slow consumer, after
+	// Config.DropAfterFullFor when set
+	OverflowDropClient OverflowPolicy = iota
+	// OverflowDropEvent keeps the client and skips the new event for it
+	OverflowDropEvent
+	// OverflowDropOldest keeps the client and discards its oldest queued event to make room
+	OverflowDropOldest
+	// OverflowBlock waits up to Config.BlockTimeout for room in the client's
+	// buffer, holding up the broadcast, then disconnects the client as a slow
+	// consumer. Clients with room get the event without waiting.
+	OverflowBlock
+)
+
+// defaultBlockTimeout is used when Config.BlockTimeout is zero
+const defaultBlockTimeout = time.Second
+
+// blockTimeout returns Config.BlockTimeout, or defaultBlockTimeout when it is zero
+func (c Config) blockTimeout() time.Duration {
+	if c.BlockTimeout > 0 {
+		return c.BlockTimeout
+	}
+	return defaultBlockTimeout
+}
+
+// fanOut enqueues event on every client accepted by match without blocking
+// and returns how many matched. With Config.ShardCount above 1 the clients
+// are split into that many shards enqueued in parallel. Full buffers are
+// handled by Config.OverflowPolicy; with OverflowDropClient, clients whose
+// buffers are full, for Config.DropAfterFullFor when set, are removed
+// together by a single goroutine, as are clients OverflowBlock gave up on.
+// Full clients of a BroadcastDeadline event are handed to its waiter instead.
+func (s *Server) fanOut(clients []*Client, event Event, match func(*Client) bool) int {
+	// Filtered clients share one normalized copy of the data
+	event.generic = &genericData{}
+
+	shards := s.config.ShardCount
+	if shards > len(clients) {
+		shards = len(clients)
+	}
+
+	var matched int
+	var full []*Client
+	if shards <= 1 {
+		matched, full = enqueueShard(clients, event, match)
+	} else {
+		matched, full = enqueueSharded(clients, shards, event, match)
+	}
+
+	if event.waiter != nil {
+		event.waiter.wait(full, event)
+		return matched
+	}
+
+	switch s.config.OverflowPolicy {
+	case OverflowDropOldest:
+		full = s.makeRoom(full, event)
+	case OverflowBlock:
+		full = s.waitForRoom(full, event)
+	}
+
+	s.eventsDropped.Add(int64(len(full)))
+	for _, client := range full {
+		event.tracker.done(client.ID, ErrClientBufferFull)
+		s.logger.Debugf("sse: dropped %q event for client %s: buffer full", event.Type, client.ID)
+	}
+	switch s.config.OverflowPolicy {
+	case OverflowDropClient:
+		full = s.slowConsumers(full)
+	case OverflowBlock:
+	default:
+		return matched
+	}
+	if len(full) > 0 {
+		go func() {
+			for _, client := range full {
+				s.disconnectClient(client, CloseReasonSlowConsumer)
+			}
+		}()
+	}
+	return matched
+}
+
+// enqueueSharded splits clients into shards and enqueues each in its own goroutine
+func enqueueSharded(clients []*Client, shards int, event Event, match func(*Client) bool) (int, []*Client) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		matched int
+		full    []*Client
+	)
+
+	size := (len(clients) + shards - 1) / shards
+	for lo := 0; lo < len(clients); lo += size {
+		hi := min(lo+size, len(clients))
+		wg.Add(1)
+		go func(shard []*Client) {
+			defer wg.Done()
+			n, f := enqueueShard(shard, event, match)
+			mu.Lock()
+			matched += n
+			full = append(full, f...)
+			mu.Unlock()
+		}(clients[lo:hi])
+	}
+	wg.Wait()
+
+	return matched, full
+}
+
+// enqueueShard enqueues event on the matching clients of one shard and
+// returns the match count and the clients whose buffers were full
+func enqueueShard(clients []*Client, event Event, match func(*Client) bool) (int, []*Client) {
+	matched := 0
+	var full []*Client
+	for _, client := range clients {
+		if match != nil && !match(client) {
+			continue
+		}
+		matched++
+		if !client.enqueue(event) {
+			full = append(full, client)
+		} else if client.fullSince.Load() != 0 {
+			client.fullSince.Store(0)
+		}
+	}
+	return matched, full
+}
+
+// makeRoom discards the oldest queued event of each full client, counting
+// it as dropped, and enqueues event in its place. It returns the clients
+// that are still full because another broadcast refilled the buffer first.
+func (s *Server) makeRoom(full []*Client, event Event) []*Client {
+	still := full[:0]
+	for _, client := range full {
+		if client.dropOldest() {
+			s.eventsDropped.Add(1)
+		}
+		if !client.enqueue(event) {
+			still = append(still, client)
+		}
+	}
+	return still
+}
+
+// waitForRoom waits up to Config.BlockTimeout for room in each full client's
+// buffer, using at most maxDeadlineWaiters goroutines, and returns the
+// clients that stayed full. Clients that close meanwhile are left out.
+func (s *Server) waitForRoom(full []*Client, event Event) []*Client {
+	if len(full) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(s.config.blockTimeout())
+	slots := make(chan struct{}, maxDeadlineWaiters)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var stuck []*Client
+	for _, client := range full {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(c *Client) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			sent, closed := c.enqueueWithin(event, time.Until(deadline))
+			if !sent && !closed {
+				mu.Lock()
+				stuck = append(stuck, c)
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	return stuck
+}
+
+// slowConsumers returns the clients among full whose buffers have stayed
+// full for Config.DropAfterFullFor, so a brief stall such as a GC pause does
+// not drop a healthy client. The others miss this event and start or keep
+// their full streak. Without the setting every full client is returned.
+func (s *Server) slowConsumers(full []*Client) []*Client {
+	if s.config.DropAfterFullFor <= 0 || len(full) == 0 {
+		return full
+	}
+
+	now := time.Now().UnixNano()
+	slow := full[:0]
+	for _, client := range full {
+		since := client.fullSince.Load()
+		if since == 0 {
+			client.fullSince.CompareAndSwap(0, now)
+			continue
+		}
+		if time.Duration(now-since) >= s.config.DropAfterFullFor {
+			slow = append(slow, client)
+		}
+	}
+	return slow
+}
@@ -0,0 +1,262 @@
+package sse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShardedFanOut(t *testing.T) {
+	for _, shards := range []int{0, 1, 4, 7, 1000} {
+		t.Run(fmt.Sprintf("%d shards", shards), func(t *testing.T) {
+			config := DefaultConfig()
+			config.ShardCount = shards
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			const clients = 100
+			all := make([]*Client, clients)
+			for i := range all {
+				// Every tenth client is already full and must be removed
+				size := 1
+				if i%10 == 0 {
+					size = 0
+				}
+				all[i] = addBareClient(server, fmt.Sprintf("client-%d", i), size)
+			}
+
+			matched := server.BroadcastFunc(func(c *ClientInfo) bool { return true }, Event{Type: "tick"})
+			if matched != clients {
+				t.Errorf("Expected %d matched clients, got %d", clients, matched)
+			}
+
+			for i, client := range all {
+				if i%10 != 0 && len(client.EventCh) != 1 {
+					t.Errorf("Expected client %d to receive the event once, got %d", i, len(client.EventCh))
+				}
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			if count := server.GetConnectionCount(); count != clients-clients/10 {
+				t.Errorf("Expected full clients removed, got %d connections", count)
+			}
+		})
+	}
+}
+
+func TestDropAfterFullFor(t *testing.T) {
+	const threshold = 100 * time.Millisecond
+
+	config := DefaultConfig()
+	config.DropAfterFullFor = threshold
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	momentary := addBareClient(server, "momentary", 1)
+	sustained := addBareClient(server, "sustained", 1)
+
+	// Both buffers fill up, neither client is dropped yet
+	server.Broadcast(Event{Data: "fill"})
+	server.Broadcast(Event{Data: "overflow"})
+
+	// The momentary client catches up within the threshold
+	time.Sleep(threshold / 2)
+	<-momentary.EventCh
+
+	time.Sleep(threshold)
+	server.Broadcast(Event{Data: "after stall"})
+
+	time.Sleep(50 * time.Millisecond)
+	if !server.HasClient("momentary") {
+		t.Error("Expected a client full for less than DropAfterFullFor to survive")
+	}
+	if server.HasClient("sustained") {
+		t.Error("Expected a client full for longer than DropAfterFullFor to be dropped")
+	}
+	if len(momentary.EventCh) != 1 {
+		t.Errorf("Expected the momentary client to receive the event after catching up, got %d queued", len(momentary.EventCh))
+	}
+
+	sustained.mu.Lock()
+	reason := sustained.reason
+	sustained.mu.Unlock()
+	if reason != CloseReasonSlowConsumer {
+		t.Errorf("Expected slow consumer close reason, got %q", reason)
+	}
+}
+
+func TestDropAfterFullForDisabled(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	addBareClient(server, "c1", 1)
+	server.Broadcast(Event{Data: "fill"})
+	server.Broadcast(Event{Data: "overflow"})
+
+	time.Sleep(50 * time.Millisecond)
+	if server.HasClient("c1") {
+		t.Error("Expected a full client to be dropped immediately by default")
+	}
+}
+
+func BenchmarkBroadcastLargeAudience(b *testing.B) {
+	const audience = 100000
+
+	for _, shards := range []int{1, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			config := DefaultConfig()
+			config.ShardCount = shards
+			config.MaxConnections = audience
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			clients := make([]*Client, audience)
+			for i := range clients {
+				clients[i] = addBareClient(server, fmt.Sprintf("client-%d", i), 1)
+			}
+
+			event := Event{Type: "benchmark", Data: "test data"}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				server.broadcast(event)
+
+				b.StopTimer()
+				for _, client := range clients {
+					<-client.EventCh
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    OverflowPolicy
+		connected bool
+		queued    []interface{}
+	}{
+		{"drop client", OverflowDropClient, false, nil},
+		{"drop event", OverflowDropEvent, true, []interface{}{0, 1}},
+		{"drop oldest", OverflowDropOldest, true, []interface{}{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.OverflowPolicy = tt.policy
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			// Nothing drains the buffer, so it fills after two events
+			client := addBareClient(server, "c1", 2)
+			for i := 0; i < 5; i++ {
+				server.Broadcast(Event{Type: "tick", Data: i})
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			if connected := server.HasClient("c1"); connected != tt.connected {
+				t.Fatalf("Expected connected %v, got %v", tt.connected, connected)
+			}
+			if !tt.connected {
+				return
+			}
+
+			var got []interface{}
+			for len(client.EventCh) > 0 {
+				got = append(got, (<-client.EventCh).Data)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.queued) {
+				t.Errorf("Expected queued %v, got %v", tt.queued, got)
+			}
+			if dropped := server.Stats().TotalEventsDropped; dropped != 3 {
+				t.Errorf("Expected 3 dropped events, got %d", dropped)
+			}
+		})
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	config := DefaultConfig()
+	config.OverflowPolicy = OverflowBlock
+	config.BlockTimeout = 200 * time.Millisecond
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	fast := addBareClient(server, "fast", 2)
+	slow := addBareClient(server, "slow", 1)
+	stuck := addBareClient(server, "stuck", 1)
+	server.Broadcast(Event{Type: "tick", Data: "fill"})
+	<-fast.EventCh
+
+	done := make(chan struct{})
+	go func() {
+		server.Broadcast(Event{Type: "tick", Data: "wait"})
+		close(done)
+	}()
+
+	// Clients with room get the event while the broadcast waits on the full ones
+	select {
+	case ev := <-fast.EventCh:
+		if ev.Data != "wait" {
+			t.Errorf("Expected the fast client to get the new event, got %v", ev.Data)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected the fast client not to wait on full clients")
+	}
+	select {
+	case <-done:
+		t.Fatal("Expected the broadcast to block on full clients")
+	default:
+	}
+
+	// Room in the slow client's buffer lets its queued event through
+	<-slow.EventCh
+	<-done
+	if ev := <-slow.EventCh; ev.Data != "wait" {
+		t.Errorf("Expected the slow client to get the event once it had room, got %v", ev.Data)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if !server.HasClient("slow") {
+		t.Error("Expected a client that made room in time to stay connected")
+	}
+	if server.HasClient("stuck") {
+		t.Error("Expected a client that stayed full past BlockTimeout to be dropped")
+	}
+	stuck.mu.Lock()
+	reason := stuck.reason
+	stuck.mu.Unlock()
+	if reason != CloseReasonSlowConsumer {
+		t.Errorf("Expected close reason %q, got %q", CloseReasonSlowConsumer, reason)
+	}
+	if dropped := server.Stats().TotalEventsDropped; dropped != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", dropped)
+	}
+}
+
+func TestOverflowBlockWakesOnClose(t *testing.T) {
+	config := DefaultConfig()
+	config.OverflowPolicy = OverflowBlock
+	config.BlockTimeout = time.Minute
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	addBareClient(server, "full", 0)
+
+	done := make(chan struct{})
+	go func() {
+		server.Broadcast(Event{Type: "tick"})
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	server.CloseClient("full")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected closing the client to end the wait without polling")
+	}
+}
@@ -0,0 +1,188 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxFilterLength bounds the size of a client-supplied filter expression
+	maxFilterLength = 256
+	// maxFilterDepth bounds how deep a filter path may reach into event data
+	maxFilterDepth = 8
+)
+
+// ErrInvalidFilter is returned when a filter expression cannot be parsed
+var ErrInvalidFilter = errors.New("sse: invalid filter expression")
+
+// eventFilter is a parsed `path == literal` or `path != literal` expression.
+// Paths start at `data`, `type` or `id`; only a single comparison is
+// supported so evaluation cost is bounded by the path depth.
+type eventFilter struct {
+	expr    string // as parsed, saved with resume tokens
+	path    []string
+	negate  bool
+	literal interface{}
+}
+
+// requestFilter returns the filter from the request's filter query parameter,
+// falling back to the filter saved under its resume token
+func requestFilter(r *http.Request, resumed *resumeState) (*eventFilter, error) {
+	if expr := r.URL.Query().Get("filter"); expr != "" {
+		return parseFilter(expr)
+	}
+	if resumed != nil {
+		return resumed.filter, nil
+	}
+	return nil, nil
+}
+
+// parseFilter parses a filter expression such as data.severity=='critical'
+func parseFilter(expr string) (*eventFilter, error) {
+	if len(expr) > maxFilterLength {
+		return nil, fmt.Errorf("%w: longer than %d bytes", ErrInvalidFilter, maxFilterLength)
+	}
+
+	op, negate := "==", false
+	idx := strings.Index(expr, "==")
+	if i := strings.Index(expr, "!="); i >= 0 && (idx < 0 || i < idx) {
+		op, negate, idx = "!=", true, i
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: expected == or !=", ErrInvalidFilter)
+	}
+
+	path := strings.Split(strings.TrimSpace(expr[:idx]), ".")
+	if len(path) > maxFilterDepth {
+		return nil, fmt.Errorf("%w: path deeper than %d", ErrInvalidFilter, maxFilterDepth)
+	}
+	for _, segment := range path {
+		if !isFilterIdent(segment) {
+			return nil, fmt.Errorf("%w: bad path segment %q", ErrInvalidFilter, segment)
+		}
+	}
+	switch path[0] {
+	case "data":
+	case "type", "id":
+		if len(path) != 1 {
+			return nil, fmt.Errorf("%w: %s has no fields", ErrInvalidFilter, path[0])
+		}
+	default:
+		return nil, fmt.Errorf("%w: path must start with data, type or id", ErrInvalidFilter)
+	}
+
+	literal, err := parseFilterLiteral(strings.TrimSpace(expr[idx+len(op):]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventFilter{expr: expr, path: path, negate: negate, literal: literal}, nil
+}
+
+// isFilterIdent reports whether s is a non-empty run of letters, digits and underscores
+func isFilterIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFilterLiteral parses a quoted string, number, boolean or null
+func parseFilterLiteral(s string) (interface{}, error) {
+	switch {
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return s[1 : len(s)-1], nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case s == "null":
+		return nil, nil
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad literal %q", ErrInvalidFilter, s)
+	}
+	return n, nil
+}
+
+// genericData caches an event's data as generic JSON values. Fan-out gives
+// every copy of a broadcast the same one, so the data is normalized once
+// per event rather than once per filtered client.
+type genericData struct {
+	once  sync.Once
+	value interface{}
+}
+
+// normalizedData returns event.Data normalized for filter paths, from the
+// event's cache when it has one
+func (e Event) normalizedData() interface{} {
+	if e.generic == nil {
+		return normalizeData(e.Data)
+	}
+	e.generic.once.Do(func() {
+		e.generic.value = normalizeData(e.Data)
+	})
+	return e.generic.value
+}
+
+// match reports whether event satisfies the filter. data supplies the
+// event's normalized data and is only called for data paths.
+func (f *eventFilter) match(event Event, data func() interface{}) bool {
+	var value interface{}
+	switch f.path[0] {
+	case "type":
+		value = event.Type
+	case "id":
+		value = event.ID
+	default:
+		value = lookupPath(data(), f.path[1:])
+	}
+
+	equal := value == f.literal
+	return equal != f.negate
+}
+
+// normalizeData converts event data into generic JSON values so paths can
+// be resolved regardless of the concrete Go type
+func normalizeData(data interface{}) interface{} {
+	switch v := data.(type) {
+	case string, nil:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil
+	}
+	return generic
+}
+
+// lookupPath walks object fields of data along path, returning nil if any segment is missing
+func lookupPath(data interface{}, path []string) interface{} {
+	for _, segment := range path {
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = obj[segment]
+	}
+	return data
+}
@@ -0,0 +1,157 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	valid := []string{
+		"data.severity=='critical'",
+		`data.level != "debug"`,
+		"data.count==3",
+		"data.nested.flag==true",
+		"type=='alert'",
+		"id!=null",
+	}
+	for _, expr := range valid {
+		if _, err := parseFilter(expr); err != nil {
+			t.Errorf("Expected %q to parse, got %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"data.severity",
+		"severity=='critical'",
+		"data.a b=='x'",
+		"data.x==critical",
+		"type.x=='a'",
+		"data." + strings.Repeat("a.", maxFilterDepth) + "b==1",
+		"data.x=='" + strings.Repeat("a", maxFilterLength) + "'",
+	}
+	for _, expr := range invalid {
+		if _, err := parseFilter(expr); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected %q to be rejected, got %v", expr, err)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	filter, err := parseFilter("data.severity=='critical'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type alert struct {
+		Severity string `json:"severity"`
+	}
+
+	tests := []struct {
+		name  string
+		data  interface{}
+		match bool
+	}{
+		{"struct data", alert{Severity: "critical"}, true},
+		{"map data", map[string]interface{}{"severity": "critical"}, true},
+		{"non-matching data", alert{Severity: "info"}, false},
+		{"string data without fields", "critical", false},
+	}
+	for _, tt := range tests {
+		event := Event{Data: tt.data}
+		if got := filter.match(event, event.normalizedData); got != tt.match {
+			t.Errorf("%s: expected match %v, got %v", tt.name, tt.match, got)
+		}
+	}
+}
+
+// countingData counts how often it is marshalled
+type countingData struct {
+	calls *atomic.Int64
+}
+
+func (d countingData) MarshalJSON() ([]byte, error) {
+	d.calls.Add(1)
+	return []byte(`{"severity":"critical"}`), nil
+}
+
+func TestFilterNormalizesOncePerEvent(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	var filters []*eventFilter
+	for _, expr := range []string{"data.severity=='critical'", "data.severity!='info'", "data.region=='eu'"} {
+		filter, err := parseFilter(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filters = append(filters, filter)
+	}
+	clients := make([]*Client, len(filters))
+	for i := range clients {
+		clients[i] = addBareClient(server, fmt.Sprintf("client-%d", i), 1)
+	}
+
+	var calls atomic.Int64
+	server.Broadcast(Event{Type: "alert", Data: countingData{calls: &calls}})
+
+	// Each client's copy shares the data normalized by the first filter
+	for i, client := range clients {
+		event := <-client.EventCh
+		want := i < 2
+		if got := filters[i].match(event, event.normalizedData); got != want {
+			t.Errorf("Expected filter %d to match %v, got %v", i, want, got)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the data to be normalized once per event, got %d marshals", n)
+	}
+}
+
+func TestHandleSSEWithFilter(t *testing.T) {
+	server := NewServer()
+
+	target := "/events?filter=" + url.QueryEscape("data.severity=='critical'")
+	req := httptest.NewRequest("GET", target, http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.Broadcast(Event{Type: "alert", Data: map[string]interface{}{"severity": "info", "msg": "skip-me"}})
+	server.Broadcast(Event{Type: "alert", Data: map[string]interface{}{"severity": "critical", "msg": "deliver-me"}})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	if strings.Contains(body, "skip-me") {
+		t.Error("Non-matching event was delivered")
+	}
+	if !strings.Contains(body, "deliver-me") {
+		t.Error("Matching event was not delivered")
+	}
+}
+
+func TestHandleSSEWithInvalidFilter(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	req := httptest.NewRequest("GET", "/events?filter=bogus", http.NoBody)
+	w := httptest.NewRecorder()
+
+	server.HandleSSE(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
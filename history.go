@@ -0,0 +1,130 @@
+package sse
+
+import (
+	"strconv"
+	"sync"
+)
+
+// history retains the most recent broadcast events for replay to new
+// connections, in a ring buffer so adding is constant time
+type history struct {
+	mu     sync.Mutex
+	events []Event // ring of size slots, oldest at head
+	head   int
+	count  int
+	seq    uint64 // sequence number of the newest event, counting from 1
+}
+
+// newHistory creates a history retaining up to size events
+func newHistory(size int) *history {
+	return &history{events: make([]Event, size)}
+}
+
+// add appends an event, evicting the oldest once full
+func (h *history) add(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count < len(h.events) {
+		h.events[(h.head+h.count)%len(h.events)] = event
+		h.count++
+	} else {
+		h.events[h.head] = event
+		h.head = (h.head + 1) % len(h.events)
+	}
+	h.seq++
+}
+
+// at returns the i-th oldest retained event. Callers must hold h.mu.
+func (h *history) at(i int) Event {
+	return h.events[(h.head+i)%len(h.events)]
+}
+
+// copyFrom returns a copy of the retained events from the i-th oldest on,
+// oldest first. Callers must hold h.mu.
+func (h *history) copyFrom(i int) []Event {
+	events := make([]Event, 0, h.count-i)
+	for ; i < h.count; i++ {
+		events = append(events, h.at(i))
+	}
+	return events
+}
+
+// latest returns the sequence number of the newest event, 0 if none
+func (h *history) latest() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.seq
+}
+
+// after returns the retained events newer than sequence number seq, oldest
+// first, along with the newest sequence number. Events evicted since seq
+// are silently skipped.
+func (h *history) after(seq uint64) ([]Event, uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	newer := h.seq - seq
+	if newer > uint64(h.count) {
+		newer = uint64(h.count)
+	}
+	return h.copyFrom(h.count - int(newer)), h.seq
+}
+
+// snapshot returns a copy of the retained events, oldest first
+func (h *history) snapshot() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.copyFrom(0)
+}
+
+// since returns the retained events after the one with the given ID,
+// oldest first, for a client resuming with Last-Event-ID. When that event is
+// no longer retained and the ID is numeric, events with a greater numeric ID
+// are returned instead, so a client that fell behind still gets what is
+// left. Events without an ID are skipped. It returns nil if nothing matches.
+func (h *history) since(id string) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := h.count - 1; i >= 0; i-- {
+		if h.at(i).ID == id {
+			return h.withIDs(i+1, nil)
+		}
+	}
+
+	last, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return h.withIDs(0, func(eventID string) bool {
+		n, err := strconv.ParseUint(eventID, 10, 64)
+		return err == nil && n > last
+	})
+}
+
+// withIDs returns a copy of the retained events from the i-th oldest on that
+// have an ID accepted by keep, or any ID when keep is nil. Callers must hold
+// h.mu.
+func (h *history) withIDs(i int, keep func(id string) bool) []Event {
+	var kept []Event
+	for ; i < h.count; i++ {
+		if event := h.at(i); event.ID != "" && (keep == nil || keep(event.ID)) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// lastID returns the ID of the newest retained event that has one
+func (h *history) lastID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := h.count - 1; i >= 0; i-- {
+		if id := h.at(i).ID; id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,323 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryEvictsOldest(t *testing.T) {
+	h := newHistory(3)
+	for i := 0; i < 5; i++ {
+		h.add(Event{ID: fmt.Sprint(i)})
+	}
+
+	events := h.snapshot()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 retained events, got %d", len(events))
+	}
+	for i, event := range events {
+		if want := fmt.Sprint(i + 2); event.ID != want {
+			t.Errorf("Expected event %d to have ID %s, got %s", i, want, event.ID)
+		}
+	}
+}
+
+func TestHistoryRingWraps(t *testing.T) {
+	h := newHistory(4)
+	for i := 0; i < 11; i++ {
+		h.add(Event{ID: fmt.Sprint(i)})
+	}
+
+	var ids []string
+	for _, event := range h.snapshot() {
+		ids = append(ids, event.ID)
+	}
+	if got := strings.Join(ids, ","); got != "7,8,9,10" {
+		t.Errorf("Expected the newest 4 events oldest first, got %q", got)
+	}
+	if id := h.lastID(); id != "10" {
+		t.Errorf("Expected last ID 10, got %q", id)
+	}
+
+	// Adding to a full history reuses its slots
+	event := Event{ID: "x"}
+	if allocs := testing.AllocsPerRun(100, func() { h.add(event) }); allocs != 0 {
+		t.Errorf("Expected add to allocate nothing, got %v allocations", allocs)
+	}
+}
+
+func TestReplayAll(t *testing.T) {
+	config := DefaultConfig()
+	config.HistorySize = 10
+	server := NewServerWithConfig(config)
+
+	for i := 0; i < 5; i++ {
+		server.Broadcast(Event{Type: "update", Data: fmt.Sprintf("past-%d", i)})
+	}
+
+	req := httptest.NewRequest("GET", "/events?replay=all", http.NoBody)
+	w := httptest.NewRecorder()
+
+	plainReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	plainW := httptest.NewRecorder()
+
+	go server.HandleSSE(w, req)
+	go server.HandleSSE(plainW, plainReq)
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.Broadcast(Event{Type: "update", Data: "live"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	last := -1
+	for _, data := range []string{"past-0", "past-1", "past-2", "past-3", "past-4", "live"} {
+		idx := strings.Index(body, "data: "+data+"\n")
+		if idx < 0 {
+			t.Fatalf("Expected %s in replaying client's stream", data)
+		}
+		if idx < last {
+			t.Errorf("Expected %s to arrive in order", data)
+		}
+		last = idx
+	}
+
+	if strings.Contains(plainW.Body.String(), "past-") {
+		t.Error("Client without replay=all should not receive history")
+	}
+}
+
+func TestCursorCookieReplay(t *testing.T) {
+	config := DefaultConfig()
+	config.HistorySize = 10
+	config.CursorCookieName = "sse_cursor"
+	server := NewServerWithConfig(config)
+
+	for i := 1; i <= 5; i++ {
+		server.Broadcast(Event{Type: "update", Data: fmt.Sprintf("event-%d", i), ID: fmt.Sprint(i)})
+	}
+
+	cookieReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	cookieReq.AddCookie(&http.Cookie{Name: "sse_cursor", Value: "2"})
+	cookieW := httptest.NewRecorder()
+
+	// The header takes precedence over the cookie
+	headerReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	headerReq.AddCookie(&http.Cookie{Name: "sse_cursor", Value: "1"})
+	headerReq.Header.Set("Last-Event-ID", "4")
+	headerW := httptest.NewRecorder()
+
+	go server.HandleSSE(cookieW, cookieReq)
+	go server.HandleSSE(headerW, headerReq)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := cookieW.Body.String()
+	for _, data := range []string{"event-1", "event-2"} {
+		if strings.Contains(body, data) {
+			t.Errorf("Did not expect %s before the cookie cursor to be replayed", data)
+		}
+	}
+	for _, data := range []string{"event-3", "event-4", "event-5"} {
+		if !strings.Contains(body, "data: "+data) {
+			t.Errorf("Expected %s to be replayed from the cookie cursor", data)
+		}
+	}
+
+	if body := headerW.Body.String(); strings.Contains(body, "event-2") || !strings.Contains(body, "event-5") {
+		t.Errorf("Expected replay from Last-Event-ID header, got %q", body)
+	}
+
+	cookies := (&http.Response{Header: cookieW.Header()}).Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sse_cursor" || cookies[0].Value != "5" {
+		t.Errorf("Expected cursor cookie set to latest event ID, got %v", cookies)
+	}
+}
+
+func TestReplayIndependentOfBufferSize(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 1
+	config.HistorySize = 100
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected tiny buffer with large history to be valid, got %v", err)
+	}
+	server := NewServerWithConfig(config)
+
+	for i := 0; i < 50; i++ {
+		server.Broadcast(Event{Type: "update", Data: fmt.Sprintf("past-%d", i)})
+	}
+
+	req := httptest.NewRequest("GET", "/events?replay=all", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected replaying client to stay connected, got %d", count)
+	}
+
+	server.Shutdown()
+
+	if count := strings.Count(w.Body.String(), "data: past-"); count != 50 {
+		t.Errorf("Expected all 50 retained events replayed, got %d", count)
+	}
+}
+
+// slowWriter is a ResponseRecorder that delays every write, simulating a
+// client on a slow link
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseRecorder.Write(p)
+}
+
+func (w *slowWriter) body() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Body.String()
+}
+
+func TestSlowClientReplayWithLiveBroadcasts(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 2
+	config.HistorySize = 200
+	config.ReplayWindow = 16
+	server := NewServerWithConfig(config)
+
+	for i := 0; i < 200; i++ {
+		server.Broadcast(Event{ID: fmt.Sprintf("past-%d", i), Data: i})
+	}
+
+	w := &slowWriter{ResponseRecorder: httptest.NewRecorder(), delay: time.Millisecond}
+	req := httptest.NewRequest("GET", "/events?replay=all", http.NoBody)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	// Live broadcasts during replay would overflow a two-event buffer
+	for i := 0; i < 20; i++ {
+		time.Sleep(5 * time.Millisecond)
+		server.Broadcast(Event{ID: fmt.Sprintf("live-%d", i), Data: i})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(w.body(), "id: live-19\n") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected slow replaying client to stay connected, got %d", count)
+	}
+
+	server.Shutdown()
+	<-done
+
+	var ids []string
+	for _, line := range strings.Split(w.body(), "\n") {
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+	}
+
+	var want []string
+	for i := 0; i < 200; i++ {
+		want = append(want, fmt.Sprintf("past-%d", i))
+	}
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprintf("live-%d", i))
+	}
+
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected full replay followed by live events in order, got %v", ids)
+	}
+}
+
+func TestHistoryAfter(t *testing.T) {
+	h := newHistory(3)
+	for i := 0; i < 5; i++ {
+		h.add(Event{ID: fmt.Sprint(i)})
+	}
+
+	events, latest := h.after(3)
+	if latest != 5 || len(events) != 2 || events[0].ID != "3" || events[1].ID != "4" {
+		t.Errorf("Expected events 3 and 4 at sequence 5, got %v at %d", events, latest)
+	}
+
+	// Evicted events are skipped
+	if events, _ = h.after(0); len(events) != 3 || events[0].ID != "2" {
+		t.Errorf("Expected the 3 retained events, got %v", events)
+	}
+}
+
+func TestHistorySince(t *testing.T) {
+	h := newHistory(5)
+	for _, id := range []string{"1", "3", "", "4", "5", "6"} {
+		h.add(Event{ID: id})
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"retained ID", "4", "5,6"},
+		{"skips events without ID", "3", "4,5,6"},
+		{"evicted numeric ID", "1", "3,4,5,6"},
+		{"newest ID", "6", ""},
+		{"ahead of history", "9", ""},
+		{"unknown non-numeric ID", "abc", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, event := range h.since(tt.id) {
+				ids = append(ids, event.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("Expected events %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+
+	invalid := map[string]func(*Config){
+		"zero buffer":      func(c *Config) { c.BufferSize = 0 },
+		"negative history": func(c *Config) { c.HistorySize = -1 },
+		"zero connections": func(c *Config) { c.MaxConnections = 0 },
+		"zero heartbeat":   func(c *Config) { c.HeartbeatInterval = 0 },
+	}
+	for name, mutate := range invalid {
+		config := DefaultConfig()
+		mutate(&config)
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
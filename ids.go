@@ -0,0 +1,23 @@
+package sse
+
+import "strconv"
+
+// assignEventID gives a published event the next sequence ID when
+// Config.AutoEventID is set and the event has no ID of its own
+func (s *Server) assignEventID(event Event) Event {
+	if !s.config.AutoEventID || event.ID != "" {
+		return event
+	}
+
+	event.ID = s.formatSeq(s.eventSeq.Add(1))
+	return event
+}
+
+// formatSeq renders a sequence number as an event ID with Config.IDFormat,
+// in decimal by default
+func (s *Server) formatSeq(seq uint64) string {
+	if s.config.IDFormat != nil {
+		return s.config.IDFormat(seq)
+	}
+	return strconv.FormatUint(seq, 10)
+}
@@ -0,0 +1,48 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoEventIDFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format func(seq uint64) string
+		want   []string
+	}{
+		{"decimal default", nil, []string{"id: 1\n", "id: 2\n"}},
+		{"zero padded", func(seq uint64) string { return fmt.Sprintf("%06d", seq) }, []string{"id: 000001\n", "id: 000002\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.AutoEventID = true
+			config.IDFormat = tt.format
+			server := NewServerWithConfig(config)
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			w := httptest.NewRecorder()
+			go server.HandleSSE(w, req)
+
+			time.Sleep(100 * time.Millisecond)
+			server.Broadcast(Event{Type: "tick", Data: 1})
+			server.Broadcast(Event{Type: "tick", Data: 2})
+			server.Broadcast(Event{Type: "tick", ID: "custom", Data: 3})
+			time.Sleep(100 * time.Millisecond)
+			server.Shutdown()
+
+			body := w.Body.String()
+			for _, want := range append(tt.want, "id: custom\n") {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %q in response, got %q", want, body)
+				}
+			}
+		})
+	}
+}
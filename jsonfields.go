@@ -0,0 +1,183 @@
+package sse
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// jsonField is a struct field as encoding/json writes it
+type jsonField struct {
+	name      string
+	index     []int // path through embedded structs, for FieldByIndex
+	omitEmpty bool
+	tagged    bool // named by its json tag
+	depth     int  // embedding depth, for resolving promoted name clashes
+}
+
+// jsonFieldSet is a cached result of jsonFieldsOf
+type jsonFieldSet struct {
+	fields []jsonField
+	ok     bool
+}
+
+// jsonFieldCache maps a struct type to its *jsonFieldSet
+var jsonFieldCache sync.Map
+
+// jsonFieldsOf lists the fields encoding/json writes for struct type t, in
+// the order it writes them. ok is false when t uses tag options the bounded
+// encoder does not reproduce, in which case t must be marshalled whole.
+func jsonFieldsOf(t reflect.Type) (fields []jsonField, ok bool) {
+	if cached, found := jsonFieldCache.Load(t); found {
+		set := cached.(*jsonFieldSet)
+		return set.fields, set.ok
+	}
+	fields, ok = typeJSONFields(t)
+	jsonFieldCache.Store(t, &jsonFieldSet{fields: fields, ok: ok})
+	return fields, ok
+}
+
+// typeJSONFields walks t and the structs it embeds breadth first, following
+// the field selection rules of encoding/json
+func typeJSONFields(t reflect.Type) ([]jsonField, bool) {
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var all []jsonField
+	visited := make(map[reflect.Type]bool)
+	current := []level{{typ: t}}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []level
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+
+			for i := 0; i < l.typ.NumField(); i++ {
+				sf := l.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				if hasTagOption(opts, "string") || hasTagOption(opts, "omitzero") {
+					return nil, false
+				}
+
+				index := append(append([]int(nil), l.index...), i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, level{typ: ft, index: index})
+					continue
+				}
+
+				field := jsonField{
+					name:      name,
+					index:     index,
+					omitEmpty: hasTagOption(opts, "omitempty"),
+					tagged:    name != "",
+					depth:     depth,
+				}
+				if field.name == "" {
+					field.name = sf.Name
+				}
+				all = append(all, field)
+			}
+		}
+		current = next
+	}
+
+	return dominantJSONFields(all), true
+}
+
+// dominantJSONFields drops fields hidden by a shallower or tagged field of
+// the same name, and fields whose name is ambiguous, then restores
+// declaration order
+func dominantJSONFields(all []jsonField) []jsonField {
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		if all[i].depth != all[j].depth {
+			return all[i].depth < all[j].depth
+		}
+		return all[i].tagged && !all[j].tagged
+	})
+
+	fields := make([]jsonField, 0, len(all))
+	for start := 0; start < len(all); {
+		end := start + 1
+		for end < len(all) && all[end].name == all[start].name {
+			end++
+		}
+		first := all[start]
+		unique := end == start+1 || all[start+1].depth > first.depth ||
+			(first.tagged && !all[start+1].tagged)
+		if unique {
+			fields = append(fields, first)
+		}
+		start = end
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+// hasTagOption reports whether a comma-separated json tag option list
+// contains option
+func hasTagOption(opts, option string) bool {
+	for opts != "" {
+		var current string
+		current, opts, _ = strings.Cut(opts, ",")
+		if current == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyJSONValue reports whether omitempty leaves v out
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
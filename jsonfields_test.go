@@ -0,0 +1,73 @@
+package sse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type fieldsBase struct {
+	ID      string `json:"id"`
+	Shared  string
+	private string
+}
+
+type fieldsExtra struct {
+	Shared string
+	Note   string `json:"note,omitempty"`
+}
+
+type fieldsPointerStamp struct{ at int }
+
+func (s *fieldsPointerStamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.at * 2)
+}
+
+type fieldsOuter struct {
+	fieldsBase
+	*fieldsExtra
+	Name    string            `json:"name"`
+	Skipped string            `json:"-"`
+	Empty   []int             `json:"empty,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	When    time.Time         `json:"when"`
+	Stamp   fieldsPointerStamp
+	Nested  *fieldsBase `json:"nested"`
+	Quoted  int         `json:"quoted,string"`
+}
+
+type fieldsPlain struct {
+	fieldsBase
+	*fieldsExtra
+	Name   string `json:"name"`
+	Count  int    `json:",omitempty"`
+	Stamp  fieldsPointerStamp
+	Nested *fieldsBase `json:"nested,omitempty"`
+}
+
+func TestEncodeBoundedMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{"embedded", fieldsPlain{fieldsBase: fieldsBase{ID: "a", Shared: "base"}, Name: "n"}},
+		{"embedded pointer", fieldsPlain{fieldsExtra: &fieldsExtra{Shared: "extra", Note: "x"}, Count: 2}},
+		{"pointer marshaler", &fieldsPlain{Stamp: fieldsPointerStamp{at: 3}, Nested: &fieldsBase{ID: "b"}}},
+		{"slice of structs", []fieldsPlain{{Name: "a"}, {Name: "b", Count: 1}}},
+		{"string option", fieldsOuter{Name: "n", Quoted: 5, When: time.Unix(0, 0).UTC()}},
+		{"anonymous", struct {
+			A int `json:"a"`
+			B struct{ C []string }
+		}{A: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeData(tt.data, 1<<20, false)
+			want, _ := json.Marshal(tt.data)
+			if err != nil || got != string(want) {
+				t.Errorf("Expected %s, got %s (%v)", want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package sse
+
+import "net/http"
+
+// requestAttributes returns the connection attributes for r from
+// Config.AttributesFunc, or nil when it is unset
+func (s *Server) requestAttributes(r *http.Request) map[string]string {
+	if s.config.AttributesFunc == nil {
+		return nil
+	}
+	return s.config.AttributesFunc(r)
+}
+
+// ConnectionCountByLabel returns the number of connected clients for each
+// value of the attribute label, as captured by Config.AttributesFunc.
+// Clients without the attribute are counted under "". The result maps
+// directly onto a Prometheus gauge vector keyed by that one label.
+func (s *Server) ConnectionCountByLabel(label string) map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, client := range s.clients {
+		counts[client.Attributes[label]]++
+	}
+	return counts
+}
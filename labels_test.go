@@ -0,0 +1,58 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnectionCountByLabel(t *testing.T) {
+	config := DefaultConfig()
+	config.AttributesFunc = func(r *http.Request) map[string]string {
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			return map[string]string{"tenant": tenant, "transport": "sse"}
+		}
+		return nil
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	for _, target := range []string{
+		"/events?tenant=acme",
+		"/events?tenant=acme",
+		"/events?tenant=globex",
+		"/events",
+	} {
+		go server.HandleSSE(httptest.NewRecorder(), httptest.NewRequest("GET", target, http.NoBody))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		label string
+		want  map[string]int
+	}{
+		{"tenant", map[string]int{"acme": 2, "globex": 1, "": 1}},
+		{"transport", map[string]int{"sse": 3, "": 1}},
+		{"region", map[string]int{"": 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if got := server.ConnectionCountByLabel(tt.label); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected counts %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	acme := 0
+	for _, info := range server.ListClients() {
+		if info.Attributes["tenant"] == "acme" {
+			acme++
+		}
+	}
+	if acme != 2 {
+		t.Errorf("Expected ListClients to expose attributes for 2 acme clients, got %d", acme)
+	}
+}
@@ -0,0 +1,178 @@
+package sse
+
+import "sync"
+
+// defaultTypeLanes is used when Config.MaxTypeLanes is zero
+const defaultTypeLanes = 8
+
+// typeLanes holds one bounded queue per event type for a client, so a flood
+// of one type cannot starve the others. Lanes are drained round-robin.
+type typeLanes struct {
+	mu    sync.Mutex
+	size  int
+	max   int // lanes created at most, later types share the last one
+	byKey map[string]chan Event
+	order []chan Event  // lanes in creation order, for round-robin draining
+	ready chan struct{} // signalled after every push
+}
+
+// newTypeLanes returns an empty lane set sized by Config.BufferSize, or nil
+// when per-type buffers are disabled
+func (s *Server) newTypeLanes() *typeLanes {
+	if !s.config.PerTypeBuffers {
+		return nil
+	}
+	return &typeLanes{
+		size:  s.config.BufferSize,
+		max:   s.config.typeLanes(),
+		byKey: make(map[string]chan Event),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// typeLanes returns Config.MaxTypeLanes, or defaultTypeLanes when it is zero
+func (c Config) typeLanes() int {
+	if c.MaxTypeLanes > 0 {
+		return c.MaxTypeLanes
+	}
+	return defaultTypeLanes
+}
+
+// lane returns the queue for eventType, creating it on first use. Once the
+// lane limit is reached, new types share the last lane created.
+func (l *typeLanes) lane(eventType string) chan Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.byKey[eventType]
+	if !ok && len(l.order) >= l.max {
+		return l.order[len(l.order)-1]
+	}
+	if !ok {
+		ch = make(chan Event, l.size)
+		l.byKey[eventType] = ch
+		l.order = append(l.order, ch)
+	}
+	return ch
+}
+
+// lanes returns a snapshot of the lanes in creation order
+func (l *typeLanes) lanes() []chan Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]chan Event(nil), l.order...)
+}
+
+// signal wakes the client loop without blocking. It is a no-op on a nil
+// lane set so callers need not check whether lanes are enabled.
+func (l *typeLanes) signal() {
+	if l == nil {
+		return
+	}
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+// len returns the number of events queued across all lanes
+func (l *typeLanes) len() int {
+	total := 0
+	for _, ch := range l.lanes() {
+		total += len(ch)
+	}
+	return total
+}
+
+// fullest returns the lane holding the most events, or nil if all are empty
+func (l *typeLanes) fullest() chan Event {
+	var fullest chan Event
+	for _, ch := range l.lanes() {
+		if len(ch) > 0 && (fullest == nil || len(ch) > len(fullest)) {
+			fullest = ch
+		}
+	}
+	return fullest
+}
+
+// next takes up to one event from every non-empty lane, in lane order
+func (l *typeLanes) next() []Event {
+	var events []Event
+	for _, ch := range l.lanes() {
+		select {
+		case event := <-ch:
+			events = append(events, event)
+		default:
+		}
+	}
+	return events
+}
+
+// queued returns the number of events waiting for the client
+func (c *Client) queued() int {
+	if c.lanes != nil {
+		return c.lanes.len()
+	}
+	return len(c.EventCh)
+}
+
+// fillRatio returns how full the client's queue is, using the fullest lane
+// when per-type buffers are enabled
+func (c *Client) fillRatio() float64 {
+	if c.lanes != nil {
+		fullest := c.lanes.fullest()
+		if fullest == nil {
+			return 0
+		}
+		return float64(len(fullest)) / float64(cap(fullest))
+	}
+	if cap(c.EventCh) == 0 {
+		return 0
+	}
+	return float64(len(c.EventCh)) / float64(cap(c.EventCh))
+}
+
+// dropOldest discards the oldest queued event, taken from the fullest lane
+// when per-type buffers are enabled. It reports whether an event was dropped.
+func (c *Client) dropOldest() bool {
+	queue := c.EventCh
+	if c.lanes != nil {
+		if queue = c.lanes.fullest(); queue == nil {
+			return false
+		}
+	}
+
+	select {
+	case <-queue:
+		return true
+	default:
+		return false
+	}
+}
+
+// queueFor returns the channel an event is enqueued on
+func (c *Client) queueFor(event Event) chan Event {
+	if c.lanes != nil {
+		return c.lanes.lane(event.Type)
+	}
+	return c.EventCh
+}
+
+// drainLanes delivers one queued event from every non-empty lane, in lane
+// order, so every type makes progress on each pass. It returns after a
+// single pass so writeLoop can flush and notice shutdown under a sustained
+// flood, signalling the lanes again if events are left.
+func (s *Server) drainLanes(client *Client) error {
+	if err := s.drainPriority(client); err != nil {
+		return err
+	}
+	for _, event := range client.lanes.next() {
+		if err := s.deliver(client, event); err != nil {
+			return err
+		}
+	}
+	if client.lanes.len() > 0 {
+		client.lanes.signal()
+	}
+	return nil
+}
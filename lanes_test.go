@@ -0,0 +1,161 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerTypeBuffersPreventStarvation(t *testing.T) {
+	const flood = 150
+
+	tests := []struct {
+		name      string
+		perType   bool
+		wantAhead bool // critical delivered ahead of most of the flood
+	}{
+		{"shared buffer", false, false},
+		{"per-type buffers", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.BufferSize = 200
+			config.PerTypeBuffers = tt.perType
+			server := NewServerWithConfig(config)
+
+			w := newStallWriter()
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+
+			done := make(chan struct{})
+			go func() {
+				server.HandleSSE(w, req)
+				close(done)
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+			w.stall()
+
+			for i := 0; i < flood; i++ {
+				server.Broadcast(Event{Type: "chatty", Data: i})
+			}
+			server.Broadcast(Event{Type: "critical", Data: "alert"})
+
+			time.Sleep(50 * time.Millisecond)
+			w.unstall()
+			time.Sleep(100 * time.Millisecond)
+			server.Shutdown()
+			<-done
+
+			body := w.Body.String()
+			critical := strings.Index(body, "event: critical\n")
+			if critical < 0 {
+				t.Fatalf("Expected critical event to be delivered, got %q", body)
+			}
+
+			ahead := strings.Count(body[:critical], "event: chatty\n")
+			if tt.wantAhead && ahead > 5 {
+				t.Errorf("Expected critical event within the first few events, %d chatty events went first", ahead)
+			}
+			if !tt.wantAhead && ahead != flood {
+				t.Errorf("Expected critical event after all %d chatty events, got %d", flood, ahead)
+			}
+			if total := strings.Count(body, "event: chatty\n"); total != flood {
+				t.Errorf("Expected all %d chatty events to be delivered, got %d", flood, total)
+			}
+		})
+	}
+}
+
+func TestPerTypeBuffersAccounting(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 4
+	config.PerTypeBuffers = true
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	client := &Client{ID: "c1", EventCh: make(chan Event, 4), server: server, lanes: server.newTypeLanes()}
+
+	for i := 0; i < 4; i++ {
+		if !client.enqueue(Event{Type: "chatty", Data: i}) {
+			t.Fatalf("Expected chatty event %d to be queued", i)
+		}
+	}
+	if client.enqueue(Event{Type: "chatty", Data: 4}) {
+		t.Error("Expected a full chatty lane to reject more events")
+	}
+	if !client.enqueue(Event{Type: "critical", Data: "alert"}) {
+		t.Error("Expected the critical lane to accept events while chatty is full")
+	}
+
+	if queued := client.queued(); queued != 5 {
+		t.Errorf("Expected 5 queued events, got %d", queued)
+	}
+	if ratio := client.fillRatio(); ratio != 1 {
+		t.Errorf("Expected fill ratio of the fullest lane to be 1, got %v", ratio)
+	}
+
+	if !client.dropOldest() {
+		t.Fatal("Expected an event to be dropped")
+	}
+	events := client.lanes.next()
+	if len(events) != 2 || events[0].Data != 1 || events[1].Type != "critical" {
+		t.Errorf("Expected oldest chatty event dropped and lanes drained round-robin, got %+v", events)
+	}
+}
+
+func TestDrainLanesSinglePass(t *testing.T) {
+	config := DefaultConfig()
+	config.PerTypeBuffers = true
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w := httptest.NewRecorder()
+	client := &Client{
+		ID:       "c1",
+		EventCh:  make(chan Event, 4),
+		conn:     w,
+		flusher:  http.NewResponseController(w),
+		server:   server,
+		lanes:    server.newTypeLanes(),
+		priority: make(chan Event, 1),
+	}
+	for i := 0; i < 3; i++ {
+		client.enqueue(Event{Type: "chatty", Data: i})
+	}
+	client.enqueue(Event{Type: "critical", Data: "alert"})
+	<-client.lanes.ready
+
+	if err := server.drainLanes(client); err != nil {
+		t.Fatalf("Expected the pass to succeed, got %v", err)
+	}
+	if queued := client.queued(); queued != 2 {
+		t.Errorf("Expected one event per lane to be written, %d left queued", queued)
+	}
+	select {
+	case <-client.lanes.ready:
+	default:
+		t.Error("Expected the lanes to be signalled again while events are left")
+	}
+}
+
+func TestPerTypeBuffersLaneLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 4
+	config.PerTypeBuffers = true
+	config.MaxTypeLanes = 2
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	lanes := server.newTypeLanes()
+	first, second := lanes.lane("a"), lanes.lane("b")
+	if lanes.lane("c") != second || lanes.lane("d") != second || lanes.lane("a") != first {
+		t.Error("Expected types past the limit to share the last lane")
+	}
+	if count := len(lanes.lanes()); count != 2 {
+		t.Errorf("Expected 2 lanes, got %d", count)
+	}
+}
@@ -0,0 +1,43 @@
+package sse
+
+import "log"
+
+// Logger receives the server's diagnostic output. Implementations must be
+// safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger discards all output. It is used when Config.Logger is nil.
+type NopLogger struct{}
+
+func (NopLogger) Debugf(string, ...interface{}) {}
+
+func (NopLogger) Infof(string, ...interface{}) {}
+
+func (NopLogger) Warnf(string, ...interface{}) {}
+
+func (NopLogger) Errorf(string, ...interface{}) {}
+
+// StdLogger writes warnings and errors to the standard log package and
+// discards debug and info output. Set Config.Logger to StdLogger{} to opt in.
+type StdLogger struct{}
+
+func (StdLogger) Debugf(string, ...interface{}) {}
+
+func (StdLogger) Infof(string, ...interface{}) {}
+
+func (StdLogger) Warnf(format string, args ...interface{}) { log.Printf(format, args...) }
+
+func (StdLogger) Errorf(format string, args ...interface{}) { log.Printf(format, args...) }
+
+// logger returns Config.Logger, or NopLogger when it is nil
+func (c Config) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return NopLogger{}
+}
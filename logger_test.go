@@ -0,0 +1,152 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger keeps every line prefixed with its level
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("DEBUG", format, args...)
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.record("INFO", format, args...)
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.record("WARN", format, args...)
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("ERROR", format, args...)
+}
+
+// contains reports whether any line has the given level and substring
+func (l *recordingLogger) contains(level, substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, level+" ") && strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoggerConnectAndDisconnect(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultConfig()
+	config.Logger = logger
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if !logger.contains("DEBUG", "client c1 connected") {
+		t.Errorf("Expected a registration line, got %q", logger.lines)
+	}
+	if !logger.contains("INFO", "client c1 disconnected") {
+		t.Errorf("Expected a removal line, got %q", logger.lines)
+	}
+}
+
+func TestLoggerSlowConsumer(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultConfig()
+	config.Logger = logger
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	addBareClient(server, "slow", 1)
+	server.Broadcast(Event{Type: "tick", Data: 1})
+	server.Broadcast(Event{Type: "tick", Data: 2})
+	time.Sleep(50 * time.Millisecond)
+
+	if !logger.contains("DEBUG", `dropped "tick" event for client slow`) {
+		t.Errorf("Expected a broadcast drop line, got %q", logger.lines)
+	}
+	if !logger.contains("WARN", "client slow removed: slow_consumer") {
+		t.Errorf("Expected a slow consumer warning, got %q", logger.lines)
+	}
+}
+
+func TestLoggerWriteError(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultConfig()
+	config.Logger = logger
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w := &flushErrWriter{ResponseRecorder: httptest.NewRecorder()}
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	w.broken.Store(true)
+	server.Broadcast(Event{Type: "tick"})
+	<-done
+
+	if !logger.contains("DEBUG", "write to client c1 failed") {
+		t.Errorf("Expected a write error line, got %q", logger.lines)
+	}
+}
+
+func TestDefaultLoggerIsSilent(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	server := NewServer()
+	defer server.Shutdown()
+	if _, ok := server.logger.(NopLogger); !ok {
+		t.Errorf("Expected NopLogger by default, got %T", server.logger)
+	}
+
+	addBareClient(server, "slow", 1)
+	server.Broadcast(Event{Type: "tick", Data: 1})
+	server.Broadcast(Event{Type: "tick", Data: 2})
+	time.Sleep(50 * time.Millisecond)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing on the standard logger, got %q", out.String())
+	}
+
+	StdLogger{}.Debugf("debug")
+	StdLogger{}.Warnf("warn %d", 1)
+	if got := out.String(); strings.Contains(got, "debug") || !strings.Contains(got, "warn 1") {
+		t.Errorf("Expected StdLogger to write only the warning, got %q", got)
+	}
+}
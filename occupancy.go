@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// occupancyWindowSamples is how many samples make up one occupancy window
+const occupancyWindowSamples = 60
+
+// occupancySampler tracks the peak queue fill ratio of each client per
+// window of samples
+type occupancySampler struct {
+	mu      sync.Mutex
+	current map[string]float64 // peak fill ratio per client in the open window
+	last    []float64          // peaks from the last completed window
+	samples int
+}
+
+// newOccupancySampler creates an empty sampler
+func newOccupancySampler() *occupancySampler {
+	return &occupancySampler{current: make(map[string]float64)}
+}
+
+// sample records the fill ratio of each client, rolling the window when full
+func (o *occupancySampler) sample(clients []*Client) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, client := range clients {
+		ratio := client.fillRatio()
+		// Missing entries read as 0, so idle clients are recorded too
+		if ratio >= o.current[client.ID] {
+			o.current[client.ID] = ratio
+		}
+	}
+
+	o.samples++
+	if o.samples >= occupancyWindowSamples {
+		o.last = peaks(o.current)
+		o.current = make(map[string]float64)
+		o.samples = 0
+	}
+}
+
+// p95 returns the 95th percentile of per-client peak fill ratios from the
+// last completed window, or from the open window if none has completed
+func (o *occupancySampler) p95() float64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	values := o.last
+	if values == nil {
+		values = peaks(o.current)
+	}
+	return percentile(values, 0.95)
+}
+
+// peaks returns the sorted values of m
+func peaks(m map[string]float64) []float64 {
+	values := make([]float64, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+	return values
+}
+
+// percentile returns the nearest-rank percentile p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// sampleOccupancy samples client buffers every interval until shutdown
+func (s *Server) sampleOccupancy(interval time.Duration) {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.occupancy.sample(s.snapshotClients(false, Event{}))
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
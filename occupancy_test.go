@@ -0,0 +1,57 @@
+package sse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{0, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	if got := percentile(values, 0.95); got != 0.9 {
+		t.Errorf("Expected p95 of 0.9, got %v", got)
+	}
+	if got := percentile(nil, 0.95); got != 0 {
+		t.Errorf("Expected p95 of empty set to be 0, got %v", got)
+	}
+}
+
+func TestOccupancyP95(t *testing.T) {
+	config := DefaultConfig()
+	config.OccupancySampleInterval = 5 * time.Millisecond
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	// Uneven load: client i holds i of 20 slots, from idle to 95% full
+	for i := 0; i < 20; i++ {
+		client := addBareClient(server, fmt.Sprintf("client-%d", i), 20)
+		for j := 0; j < i; j++ {
+			client.EventCh <- Event{Type: "backlog"}
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	stats := server.Stats()
+	if stats.OccupancyP95 < 0.85 || stats.OccupancyP95 > 0.95 {
+		t.Errorf("Expected p95 occupancy near 0.9, got %v", stats.OccupancyP95)
+	}
+	if stats.Connections != 20 {
+		t.Errorf("Expected 20 connections, got %d", stats.Connections)
+	}
+	if stats.TotalBuffered != 190 {
+		t.Errorf("Expected 190 buffered events, got %d", stats.TotalBuffered)
+	}
+}
+
+func TestOccupancyDisabled(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "full", 1)
+	client.EventCh <- Event{Type: "backlog"}
+
+	if p95 := server.Stats().OccupancyP95; p95 != 0 {
+		t.Errorf("Expected no occupancy without sampling, got %v", p95)
+	}
+}
@@ -0,0 +1,38 @@
+package sse
+
+// orderedBroadcast is a broadcast handed to the ordering goroutine, see
+// Config.OrderedBroadcasts
+type orderedBroadcast struct {
+	event   Event
+	match   func(*Client) bool
+	publish bool
+	matched chan int
+}
+
+// orderBroadcasts delivers queued broadcasts one at a time, so every client
+// receives them in the same order however many goroutines broadcast
+func (s *Server) orderBroadcasts() {
+	defer s.background.Done()
+
+	for {
+		select {
+		case b := <-s.ordered:
+			b.matched <- s.deliverBroadcast(b.event, b.match, b.publish)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// submitOrdered queues a broadcast for the ordering goroutine and waits
+// until it has been delivered, returning the number of clients matched. It
+// returns 0 once the server is shutting down.
+func (s *Server) submitOrdered(event Event, match func(*Client) bool, publish bool) int {
+	b := orderedBroadcast{event: event, match: match, publish: publish, matched: make(chan int, 1)}
+	select {
+	case s.ordered <- b:
+	case <-s.ctx.Done():
+		return 0
+	}
+	return <-b.matched
+}
@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedBroadcastsConsistentAcrossClients(t *testing.T) {
+	config := DefaultConfig()
+	config.OrderedBroadcasts = true
+	config.ShardCount = 4
+	server := NewServerWithConfig(config)
+
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	go server.HandleSSE(first, httptest.NewRequest("GET", "/events", http.NoBody))
+	go server.HandleSSE(second, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+
+	const producers, perProducer = 4, 100
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				server.Broadcast(Event{Type: "tick", Data: fmt.Sprintf("tick-%d-%d", p, i)})
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	time.Sleep(200 * time.Millisecond)
+	server.Shutdown()
+
+	order := func(body string) []string {
+		var data []string
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(line, "data: tick-") {
+				data = append(data, line)
+			}
+		}
+		return data
+	}
+	a, b := order(first.Body.String()), order(second.Body.String())
+	if len(a) != producers*perProducer || len(b) != producers*perProducer {
+		t.Fatalf("Expected %d events per client, got %d and %d", producers*perProducer, len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Clients diverge at event %d: %s vs %s", i, a[i], b[i])
+		}
+	}
+}
+
+func TestOrderedBroadcastAfterShutdown(t *testing.T) {
+	config := DefaultConfig()
+	config.OrderedBroadcasts = true
+	server := NewServerWithConfig(config)
+	server.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		server.Broadcast(Event{Type: "tick", Data: "late"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Broadcast to return after shutdown")
+	}
+}
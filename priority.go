@@ -0,0 +1,64 @@
+package sse
+
+// priorityBufferSize is the capacity of each client's priority queue
+const priorityBufferSize = 16
+
+// SendPriority queues an event for a single client ahead of any broadcasts
+// already waiting in its buffer, for direct messages that must not sit
+// behind a broadcast backlog. Priority events keep their order among
+// themselves. It returns ErrClientNotFound for an unknown ID, the
+// ValidateEvent error for data that does not match a registered schema, and
+// ErrClientBufferFull when the client's priority queue is full; the client
+// is not disconnected in that case.
+func (s *Server) SendPriority(clientID string, event Event) error {
+	if err := s.ValidateEvent(event); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	event.Data = snapshotData(event.Data)
+	event.route = routeDirect
+	if !client.enqueuePriority(event) {
+		return ErrClientBufferFull
+	}
+	return nil
+}
+
+// enqueuePriority queues event on the client's priority queue without
+// blocking. Events for closed clients are discarded.
+func (c *Client) enqueuePriority(event Event) bool {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.closed {
+		return true
+	}
+
+	select {
+	case c.priority <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainPriority delivers every queued priority event
+func (s *Server) drainPriority(client *Client) error {
+	for {
+		select {
+		case event := <-client.priority:
+			if err := s.deliver(client, event); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendPriorityJumpsQueue(t *testing.T) {
+	const backlog = 50
+
+	config := DefaultConfig()
+	config.BufferSize = backlog + 10
+	config.ClientIDFunc = func(*http.Request) string { return "target" }
+	server := NewServerWithConfig(config)
+
+	w := newStallWriter()
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	w.stall()
+
+	for i := 0; i < backlog; i++ {
+		server.Broadcast(Event{Type: "routine", Data: i})
+	}
+	if err := server.SendPriority("target", Event{Type: "direct", Data: "urgent"}); err != nil {
+		t.Fatalf("SendPriority failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	w.unstall()
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+	<-done
+
+	body := w.Body.String()
+	direct := strings.Index(body, "event: direct\n")
+	if direct < 0 {
+		t.Fatalf("Expected the priority event to be delivered, got %q", body)
+	}
+	// At most the broadcast already being written when the writer stalled goes first
+	if ahead := strings.Count(body[:direct], "event: routine\n"); ahead > 1 {
+		t.Errorf("Expected the priority event ahead of the backlog, %d broadcasts went first", ahead)
+	}
+	if total := strings.Count(body, "event: routine\n"); total != backlog {
+		t.Errorf("Expected all %d broadcasts to be delivered, got %d", backlog, total)
+	}
+}
+
+func TestSendPriorityErrors(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if err := server.SendPriority("missing", Event{Data: "x"}); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	client := addBareClient(server, "bare", 1)
+	client.priority = make(chan Event, 1)
+	if err := server.SendPriority("bare", Event{Data: "first"}); err != nil {
+		t.Fatalf("Expected the first priority event to be queued, got %v", err)
+	}
+	if err := server.SendPriority("bare", Event{Data: "second"}); !errors.Is(err, ErrClientBufferFull) {
+		t.Errorf("Expected ErrClientBufferFull, got %v", err)
+	}
+}
+
+func TestSendPriorityValidatesAndCopies(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+	server.RegisterEventType("chat", chatMessage{})
+
+	client := addBareClient(server, "bare", 1)
+	client.priority = make(chan Event, 1)
+
+	invalid := Event{Type: "chat", Data: map[string]interface{}{"user": "ana"}}
+	if err := server.SendPriority("bare", invalid); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("Expected ErrSchemaMismatch, got %v", err)
+	}
+	if len(client.priority) != 0 {
+		t.Error("Expected the invalid event not to be queued")
+	}
+
+	data := map[string]interface{}{"user": "ana", "text": "hi"}
+	if err := server.SendPriority("bare", Event{Type: "chat", Data: data}); err != nil {
+		t.Fatalf("Expected the valid event to be queued, got %v", err)
+	}
+	data["text"] = "changed"
+	if queued := <-client.priority; queued.Data.(map[string]interface{})["text"] != "hi" {
+		t.Errorf("Expected the queued data to be a copy, got %v", queued.Data)
+	}
+}
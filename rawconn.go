@@ -0,0 +1,22 @@
+package sse
+
+import (
+	"context"
+	"net"
+)
+
+// connKey is the context key under which ConnContext stores the connection
+type connKey struct{}
+
+// ConnContext records the underlying connection in the request context so
+// Config.OnRawConn can reach it without hijacking. Install it as the
+// ConnContext of the http.Server serving HandleSSE.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// rawConn returns the connection recorded by ConnContext, or nil
+func rawConn(ctx context.Context) net.Conn {
+	conn, _ := ctx.Value(connKey{}).(net.Conn)
+	return conn
+}
@@ -0,0 +1,59 @@
+package sse
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnRawConn(t *testing.T) {
+	conns := make(chan net.Conn, 1)
+	config := DefaultConfig()
+	config.OnRawConn = func(conn net.Conn) {
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			if err := tcp.SetNoDelay(true); err != nil {
+				t.Errorf("SetNoDelay failed: %v", err)
+			}
+		}
+		conns <- conn
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(server.HandleSSE))
+	ts.Config.ConnContext = ConnContext
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	select {
+	case conn := <-conns:
+		if conn == nil {
+			t.Error("Expected a non-nil connection")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnRawConn was not invoked")
+	}
+}
+
+func TestOnRawConnWithoutConnContext(t *testing.T) {
+	config := DefaultConfig()
+	config.OnRawConn = func(net.Conn) {
+		t.Error("Expected OnRawConn not to be invoked without ConnContext")
+	}
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+}
@@ -0,0 +1,91 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultReconnectWindow is used when Config.ReconnectWindow is zero
+const defaultReconnectWindow = 5 * time.Minute
+
+// reconnectTracker records connection times per identity within a sliding window
+type reconnectTracker struct {
+	mu       sync.Mutex
+	window   time.Duration
+	connects map[string][]time.Time
+	swept    time.Time // last full sweep, see sweep
+}
+
+// newReconnectTracker creates a tracker over the given window
+func newReconnectTracker(window time.Duration) *reconnectTracker {
+	if window <= 0 {
+		window = defaultReconnectWindow
+	}
+	return &reconnectTracker{
+		window:   window,
+		connects: make(map[string][]time.Time),
+	}
+}
+
+// record notes a connection for identity at now
+func (t *reconnectTracker) record(identity string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.connects[identity] = append(t.prune(identity, now), now)
+}
+
+// rate returns reconnects per minute for identity over the window. The
+// first connection in the window is not counted as a reconnect.
+func (t *reconnectTracker) rate(identity string, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	times := t.prune(identity, now)
+	if len(times) < 2 {
+		return 0
+	}
+	return float64(len(times)-1) / t.window.Minutes()
+}
+
+// prune drops connection times older than the window and returns the rest.
+// Callers must hold t.mu.
+func (t *reconnectTracker) prune(identity string, now time.Time) []time.Time {
+	times := t.connects[identity]
+	cutoff := now.Add(-t.window)
+
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	times = times[i:]
+
+	if len(times) == 0 {
+		delete(t.connects, identity)
+		return nil
+	}
+	t.connects[identity] = times
+	return times
+}
+
+// sweep drops every identity with no connection left in the window, at most
+// once per window, so identities that never reconnect are not kept forever
+func (t *reconnectTracker) sweep(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.swept) < t.window {
+		return
+	}
+	t.swept = now
+	for identity := range t.connects {
+		t.prune(identity, now)
+	}
+}
+
+// ReconnectRate returns how many times per minute the given identity has
+// reconnected within Config.ReconnectWindow. Identities are only tracked
+// when Config.IdentityFunc is set.
+func (s *Server) ReconnectRate(identity string) float64 {
+	return s.reconnects.rate(identity, time.Now())
+}
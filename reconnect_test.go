@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReconnectTrackerWindow(t *testing.T) {
+	tracker := newReconnectTracker(time.Minute)
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		tracker.record("alice", start.Add(time.Duration(i)*time.Second))
+	}
+
+	if rate := tracker.rate("alice", start.Add(5*time.Second)); rate != 3 {
+		t.Errorf("Expected 3 reconnects per minute, got %v", rate)
+	}
+
+	// All connects fall out of the window
+	if rate := tracker.rate("alice", start.Add(2*time.Minute)); rate != 0 {
+		t.Errorf("Expected rate to decay to 0, got %v", rate)
+	}
+}
+
+func TestReconnectTrackerSweep(t *testing.T) {
+	tracker := newReconnectTracker(time.Minute)
+	start := time.Now()
+
+	tracker.record("alice", start)
+	tracker.record("bob", start.Add(50*time.Second))
+	tracker.sweep(start.Add(30 * time.Second))
+
+	// Alice never reconnects or is queried, yet her entry is dropped
+	tracker.sweep(start.Add(90 * time.Second))
+	tracker.mu.Lock()
+	_, alice := tracker.connects["alice"]
+	_, bob := tracker.connects["bob"]
+	tracker.mu.Unlock()
+	if alice || !bob {
+		t.Errorf("Expected only alice to be swept, got alice=%v bob=%v", alice, bob)
+	}
+
+	// Sweeps run at most once per window
+	tracker.sweep(start.Add(2 * time.Minute))
+	tracker.mu.Lock()
+	_, bob = tracker.connects["bob"]
+	tracker.mu.Unlock()
+	if !bob {
+		t.Error("Expected a sweep within a window of the last one to be skipped")
+	}
+	tracker.sweep(start.Add(3 * time.Minute))
+	if len(tracker.connects) != 0 {
+		t.Errorf("Expected every expired identity to be swept, got %v", tracker.connects)
+	}
+}
+
+func TestReconnectRate(t *testing.T) {
+	config := DefaultConfig()
+	config.ReconnectWindow = time.Minute
+	config.IdentityFunc = func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	// Simulate a flaky client connecting and dropping repeatedly
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+		req.Header.Set("X-User", "flaky")
+
+		done := make(chan struct{})
+		go func() {
+			server.HandleSSE(httptest.NewRecorder(), req)
+			close(done)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		<-done
+	}
+
+	if rate := server.ReconnectRate("flaky"); rate != 4 {
+		t.Errorf("Expected 4 reconnects per minute, got %v", rate)
+	}
+	if rate := server.ReconnectRate("stable"); rate != 0 {
+		t.Errorf("Expected unknown identity to have rate 0, got %v", rate)
+	}
+}
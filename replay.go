@@ -0,0 +1,96 @@
+package sse
+
+// replay writes sticky events and retained history to a new client. Events
+// are written directly rather than queued, so the pace follows the client's
+// connection, in windows of Config.ReplayWindow. Between windows the live
+// queue is drained so targeted events and heartbeats cannot overflow it, and
+// once the initial events are out, broadcasts recorded in the meantime are
+// caught up from history until the client is level with live delivery.
+func (s *Server) replay(client *Client, events []Event) error {
+	window := s.config.ReplayWindow
+	if window <= 0 {
+		window = s.config.BufferSize
+	}
+
+	for {
+		for len(events) > 0 {
+			n := min(window, len(events))
+			for _, event := range events[:n] {
+				event.route = routeReplay
+				if err := s.deliver(client, event); err != nil {
+					return err
+				}
+			}
+			events = events[n:]
+
+			if err := s.drainQueued(client); err != nil {
+				return err
+			}
+		}
+
+		if events = s.catchUp(client); len(events) == 0 {
+			return nil
+		}
+	}
+}
+
+// catchUp returns history recorded since the client's replay position. When
+// there is none the client is switched to live delivery under the same lock
+// broadcasts record history with, so no event is missed or sent twice.
+func (s *Server) catchUp(client *Client) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !client.replaying {
+		return nil
+	}
+
+	for {
+		events, latest := s.history.after(client.replayed)
+		client.replayed = latest
+		if len(events) == 0 {
+			client.replaying = false
+			return nil
+		}
+		// Events for types the client is not subscribed to are skipped
+		if events = s.visibleLocked(client, events); len(events) > 0 {
+			return events
+		}
+	}
+}
+
+// drainQueued delivers the events already waiting on the client's queue
+func (s *Server) drainQueued(client *Client) error {
+	if client.lanes != nil {
+		return s.drainLanes(client)
+	}
+
+	for n := len(client.EventCh); n > 0; n-- {
+		event, ok := <-client.EventCh
+		if !ok {
+			return errClientClosed
+		}
+		if err := s.deliver(client, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdReplayLocked counts a replay towards ShutdownContext. Replays accepted
+// after it started are not waited for. Callers must hold s.mu.
+func (s *Server) holdReplayLocked(client *Client, events []Event) {
+	if len(events) == 0 || s.stopping {
+		return
+	}
+	client.replayHeld = true
+	s.replays.Add(1)
+}
+
+// releaseReplay marks the client's replay as finished, at most once
+func (s *Server) releaseReplay(client *Client) {
+	if client.replayHeld {
+		client.replayHeld = false
+		s.replays.Done()
+	}
+}
@@ -0,0 +1,231 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// resumeTokenHeader carries the resume token on the response and on reconnect
+	resumeTokenHeader = "X-SSE-Resume-Token"
+	// resumeTokenCookie carries the resume token for browsers, which cannot set headers on EventSource
+	resumeTokenCookie = "sse_resume_token"
+)
+
+// ResumeState is what a resume token restores on reconnect, in a form a
+// ResumeStore can serialize
+type ResumeState struct {
+	Types       []string `json:"types,omitempty"`         // event types subscribed to
+	Filter      string   `json:"filter,omitempty"`        // filter expression, empty for none
+	LastEventID string   `json:"last_event_id,omitempty"` // ID of the last event written to the connection
+}
+
+// ResumeStore keeps resume tokens for Config.ResumeTokenTTL. Servers sharing
+// a store, backed by Redis or a database, let a client resume on whichever
+// instance it reconnects to. Implementations must be safe for concurrent use.
+type ResumeStore interface {
+	// Load returns the state saved under token, or nil when the token is
+	// unknown or has expired
+	Load(token string) (*ResumeState, error)
+	// Hold saves state under token for a live connection; a held token does
+	// not expire until it is released
+	Hold(token string, state ResumeState) error
+	// Release saves the final state of the connection holding token and
+	// lets the token expire after ttl
+	Release(token string, state ResumeState, ttl time.Duration) error
+}
+
+// memoryResumeStore is the in-process ResumeStore
+type memoryResumeStore struct {
+	mu     sync.Mutex
+	tokens map[string]*storedResume
+}
+
+// storedResume is a token's state and when it expires, zero while held
+type storedResume struct {
+	state   ResumeState
+	expires time.Time
+}
+
+// NewMemoryResumeStore returns a ResumeStore kept in memory. It is the
+// default, and can be shared by servers in the same process.
+func NewMemoryResumeStore() ResumeStore {
+	return &memoryResumeStore{tokens: make(map[string]*storedResume)}
+}
+
+// Load implements ResumeStore
+func (m *memoryResumeStore) Load(token string) (*ResumeState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.tokens[token]
+	if !ok || stored.expired(time.Now()) {
+		return nil, nil
+	}
+	state := stored.state
+	return &state, nil
+}
+
+// Hold implements ResumeStore, pruning expired tokens as it goes
+func (m *memoryResumeStore) Hold(token string, state ResumeState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for t, stored := range m.tokens {
+		if stored.expired(now) {
+			delete(m.tokens, t)
+		}
+	}
+
+	m.tokens[token] = &storedResume{state: state}
+	return nil
+}
+
+// Release implements ResumeStore
+func (m *memoryResumeStore) Release(token string, state ResumeState, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[token] = &storedResume{state: state, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// expired reports whether the token can no longer be used
+func (s *storedResume) expired(now time.Time) bool {
+	return !s.expires.IsZero() && now.After(s.expires)
+}
+
+// resumeState is a loaded ResumeState with its filter parsed
+type resumeState struct {
+	filter      *eventFilter
+	types       []string
+	lastEventID string
+}
+
+// newResumeStore returns the store resume tokens are kept in, or nil when
+// resume tokens are disabled
+func newResumeStore(config Config) ResumeStore {
+	if config.ResumeTokenTTL <= 0 {
+		return nil
+	}
+	if config.ResumeStore != nil {
+		return config.ResumeStore
+	}
+	return NewMemoryResumeStore()
+}
+
+// lookupResume returns the token presented by r and its state, or an empty
+// token and nil when it is missing, unknown, expired or cannot be loaded
+func (s *Server) lookupResume(r *http.Request) (string, *resumeState) {
+	if s.resumes == nil {
+		return "", nil
+	}
+
+	token := r.Header.Get(resumeTokenHeader)
+	if token == "" {
+		if cookie, err := r.Cookie(resumeTokenCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		return "", nil
+	}
+
+	state, err := s.resumes.Load(token)
+	if err != nil {
+		s.logger.Warnf("sse: loading resume token: %v", err)
+		return "", nil
+	}
+	if state == nil {
+		return "", nil
+	}
+
+	resumed := &resumeState{types: state.Types, lastEventID: state.LastEventID}
+	if state.Filter != "" {
+		if resumed.filter, err = parseFilter(state.Filter); err != nil {
+			s.logger.Warnf("sse: resume token filter: %v", err)
+			return "", nil
+		}
+	}
+	return token, resumed
+}
+
+// generateResumeToken returns an unguessable token
+func generateResumeToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails when the OS entropy source is unusable
+		panic("sse: generating resume token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// resumeStateOf returns the client's current state for its resume token
+func (s *Server) resumeStateOf(client *Client) ResumeState {
+	client.mu.Lock()
+	lastEventID := client.lastEventID
+	client.mu.Unlock()
+
+	s.mu.RLock()
+	types := client.types
+	s.mu.RUnlock()
+
+	state := ResumeState{Types: types, LastEventID: lastEventID}
+	if client.filter != nil {
+		state.Filter = client.filter.expr
+	}
+	return state
+}
+
+// issueResumeToken hands the client a resume token, reusing the one it
+// resumed with, in both a response header and a cookie. It is a no-op when
+// resume tokens are disabled, and the client goes without a token if the
+// store fails.
+func (s *Server) issueResumeToken(w http.ResponseWriter, client *Client, token string, resumed *resumeState) {
+	if s.resumes == nil {
+		return
+	}
+
+	// Until it writes an event, the client is where it resumed from
+	if resumed != nil {
+		client.mu.Lock()
+		client.lastEventID = resumed.lastEventID
+		client.mu.Unlock()
+	}
+
+	if token == "" {
+		token = generateResumeToken()
+	}
+	if err := s.resumes.Hold(token, s.resumeStateOf(client)); err != nil {
+		s.logger.Warnf("sse: saving resume token for client %s: %v", client.ID, err)
+		return
+	}
+	client.resumeToken = token
+
+	w.Header().Set(resumeTokenHeader, client.resumeToken)
+	http.SetCookie(w, &http.Cookie{
+		Name:     resumeTokenCookie,
+		Value:    client.resumeToken,
+		Path:     "/",
+		MaxAge:   int(s.config.ResumeTokenTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// releaseResumeToken saves the client's replay position and current
+// subscriptions under its resume token once the connection has ended
+func (s *Server) releaseResumeToken(client *Client) {
+	if client.resumeToken == "" {
+		return
+	}
+
+	err := s.resumes.Release(client.resumeToken, s.resumeStateOf(client), s.config.ResumeTokenTTL)
+	if err != nil {
+		s.logger.Warnf("sse: releasing resume token for client %s: %v", client.ID, err)
+	}
+}
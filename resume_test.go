@@ -0,0 +1,243 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connectResumable runs HandleSSE for target with the given resume token
+// until stop is called, returning the recorder holding the response
+func connectResumable(server *Server, target, token string) (*httptest.ResponseRecorder, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", target, http.NoBody).WithContext(ctx)
+	if token != "" {
+		req.Header.Set(resumeTokenHeader, token)
+	}
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	return w, func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestResumeTokenRestoresState(t *testing.T) {
+	config := DefaultConfig()
+	config.HistorySize = 10
+	config.ResumeTokenTTL = time.Minute
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	critical := func(id, msg string) Event {
+		return Event{ID: id, Type: "alert", Data: map[string]interface{}{"severity": "critical", "msg": msg}}
+	}
+	info := func(id, msg string) Event {
+		return Event{ID: id, Type: "alert", Data: map[string]interface{}{"severity": "info", "msg": msg}}
+	}
+
+	target := "/events?types=alert&filter=" + url.QueryEscape("data.severity=='critical'")
+	w, stop := connectResumable(server, target, "")
+	server.Broadcast(critical("e1", "first"))
+	server.Broadcast(info("e2", "second"))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	token := w.Header().Get(resumeTokenHeader)
+	if token == "" {
+		t.Fatal("Expected a resume token header")
+	}
+	if strings.Contains(token, "client_") {
+		t.Errorf("Expected an opaque token independent of the client ID, got %q", token)
+	}
+	if cookie := w.Result().Cookies(); len(cookie) != 1 || cookie[0].Name != resumeTokenCookie || cookie[0].Value != token {
+		t.Errorf("Expected the token in a %s cookie, got %+v", resumeTokenCookie, cookie)
+	}
+
+	// Missed while disconnected
+	server.Broadcast(info("e3", "missed-info"))
+	server.Broadcast(critical("e4", "missed-critical"))
+
+	// Reconnect with only the token, no filter, types or Last-Event-ID
+	w, stop = connectResumable(server, "/events", token)
+	server.Broadcast(info("e5", "live-info"))
+	server.Broadcast(critical("e6", "live-critical"))
+	server.BroadcastToType("alert", critical("e7", "typed-critical"))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	body := w.Body.String()
+	if strings.Contains(body, "first") {
+		t.Error("Expected events before the saved position not to be replayed")
+	}
+	if !strings.Contains(body, "missed-critical") || !strings.Contains(body, "live-critical") {
+		t.Errorf("Expected missed and live critical events, got %q", body)
+	}
+	if !strings.Contains(body, "typed-critical") {
+		t.Errorf("Expected the restored subscription to receive typed broadcasts, got %q", body)
+	}
+	if strings.Contains(body, "missed-info") || strings.Contains(body, "live-info") {
+		t.Errorf("Expected the restored filter to skip info events, got %q", body)
+	}
+	if got := w.Header().Get(resumeTokenHeader); got != token {
+		t.Errorf("Expected the resumed token %q to be reissued, got %q", token, got)
+	}
+}
+
+func TestResumeTokenRejected(t *testing.T) {
+	config := DefaultConfig()
+	config.HistorySize = 10
+	config.ResumeTokenTTL = 50 * time.Millisecond
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w, stop := connectResumable(server, "/events", "")
+	server.Broadcast(Event{ID: "e1", Data: "one"})
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	token := w.Header().Get(resumeTokenHeader)
+
+	server.Broadcast(Event{ID: "e2", Data: "missed"})
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", token},
+		{"unknown", "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, stop := connectResumable(server, "/events", tt.token)
+			stop()
+
+			if strings.Contains(w.Body.String(), "missed") {
+				t.Error("Expected no replay for a rejected token")
+			}
+			if got := w.Header().Get(resumeTokenHeader); got == "" || got == tt.token {
+				t.Errorf("Expected a fresh token, got %q", got)
+			}
+		})
+	}
+}
+
+func TestResumeTokensDisabled(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	w, stop := connectResumable(server, "/events", "")
+	stop()
+
+	if got := w.Header().Get(resumeTokenHeader); got != "" {
+		t.Errorf("Expected no resume token when ResumeTokenTTL is zero, got %q", got)
+	}
+}
+
+// jsonResumeStore is a ResumeStore that keeps only serialized state, like a
+// Redis-backed store shared by several instances would
+type jsonResumeStore struct {
+	mu     sync.Mutex
+	tokens map[string][]byte
+}
+
+func (j *jsonResumeStore) Load(token string) (*ResumeState, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	b, ok := j.tokens[token]
+	if !ok {
+		return nil, nil
+	}
+	var state ResumeState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (j *jsonResumeStore) Hold(token string, state ResumeState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tokens[token] = b
+	return nil
+}
+
+func (j *jsonResumeStore) Release(token string, state ResumeState, _ time.Duration) error {
+	return j.Hold(token, state)
+}
+
+func TestResumeTokenAcrossInstances(t *testing.T) {
+	store := &jsonResumeStore{tokens: make(map[string][]byte)}
+	newInstance := func() *Server {
+		config := DefaultConfig()
+		config.HistorySize = 10
+		config.ResumeTokenTTL = time.Minute
+		config.ResumeStore = store
+		return NewServerWithConfig(config)
+	}
+	first, second := newInstance(), newInstance()
+	defer first.Shutdown()
+	defer second.Shutdown()
+
+	alert := func(id, severity string) Event {
+		return Event{ID: id, Type: "alert", Data: map[string]interface{}{"severity": severity, "msg": severity + "-" + id}}
+	}
+
+	target := "/events?types=alert&filter=" + url.QueryEscape("data.severity=='critical'")
+	w, stop := connectResumable(first, target, "")
+	first.Broadcast(alert("e1", "critical"))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	token := w.Header().Get(resumeTokenHeader)
+	if token == "" {
+		t.Fatal("Expected a resume token header")
+	}
+
+	// The second instance saw the same stream while the client was away
+	second.Broadcast(alert("e1", "critical"))
+	second.Broadcast(alert("e2", "critical"))
+
+	w, stop = connectResumable(second, "/events", token)
+	second.BroadcastToType("alert", alert("e3", "info"))
+	second.BroadcastToType("alert", alert("e4", "critical"))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	body := w.Body.String()
+	if strings.Contains(body, "critical-e1") {
+		t.Error("Expected the position saved by the first instance to skip e1")
+	}
+	if !strings.Contains(body, "critical-e2") {
+		t.Errorf("Expected the missed event replayed on the second instance, got %q", body)
+	}
+	if !strings.Contains(body, "critical-e4") {
+		t.Errorf("Expected the restored subscription to receive typed broadcasts, got %q", body)
+	}
+	if strings.Contains(body, "info-e3") {
+		t.Errorf("Expected the restored filter to skip info events, got %q", body)
+	}
+
+	state, err := store.Load(token)
+	if err != nil || state == nil || state.LastEventID != "e4" {
+		t.Errorf("Expected the second instance to save position e4, got %+v, %v", state, err)
+	}
+}
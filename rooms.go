@@ -0,0 +1,131 @@
+package sse
+
+import "sort"
+
+// JoinRoom adds a connected client to room. Rooms are created on first join
+// and removed once their last member leaves or disconnects. It returns
+// ErrClientNotFound for an unknown ID.
+func (s *Server) JoinRoom(clientID, room string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[clientID]
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	members := s.rooms[room]
+	if members == nil {
+		members = make(map[string]*Client)
+		s.rooms[room] = members
+	}
+	members[clientID] = client
+	if client.rooms == nil {
+		client.rooms = make(map[string]bool)
+	}
+	client.rooms[room] = true
+	return nil
+}
+
+// LeaveRoom removes a connected client from room. Leaving a room the client
+// is not in is a no-op. It returns ErrClientNotFound for an unknown ID.
+func (s *Server) LeaveRoom(clientID, room string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[clientID]
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	delete(client.rooms, room)
+	if members := s.rooms[room]; members != nil {
+		delete(members, clientID)
+		if len(members) == 0 {
+			delete(s.rooms, room)
+		}
+	}
+	return nil
+}
+
+// BroadcastToRoom sends an event to every member of room and returns how
+// many clients matched. Like BroadcastFunc it is not recorded in history.
+func (s *Server) BroadcastToRoom(room string, event Event) int {
+	if !s.throttleBroadcast() {
+		return 0
+	}
+
+	s.mu.RLock()
+	members := make(map[*Client]bool, len(s.rooms[room]))
+	for _, client := range s.rooms[room] {
+		members[client] = true
+	}
+	s.mu.RUnlock()
+
+	if len(members) == 0 {
+		return 0
+	}
+
+	event.route = "room:" + room
+	matched := 0
+	for _, e := range s.expandEvent(event) {
+		matched = s.broadcastTo(e, func(c *Client) bool {
+			return members[c]
+		}, false)
+	}
+	return matched
+}
+
+// Rooms returns the names of rooms that currently have members, sorted
+func (s *Server) Rooms() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := make([]string, 0, len(s.rooms))
+	for room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// BroadcastToNonMembers sends an event to every connected client that is
+// not a member of room, for example to advertise the room, and returns how
+// many clients matched. Like BroadcastFunc it is not recorded in history.
+func (s *Server) BroadcastToNonMembers(room string, event Event) int {
+	if !s.throttleBroadcast() {
+		return 0
+	}
+
+	s.mu.RLock()
+	members := make(map[*Client]bool, len(s.rooms[room]))
+	for _, client := range s.rooms[room] {
+		members[client] = true
+	}
+	s.mu.RUnlock()
+
+	event.route = "not-room:" + room
+	matched := 0
+	for _, e := range s.expandEvent(event) {
+		matched = s.broadcastTo(e, func(c *Client) bool {
+			return !members[c]
+		}, false)
+	}
+	return matched
+}
+
+// leaveAllRoomsLocked removes client from the rooms it joined, dropping
+// rooms left empty. Like unsubscribeAllLocked it only touches the client's
+// own entries and is safe to repeat. Callers must hold s.mu.
+func (s *Server) leaveAllRoomsLocked(client *Client) {
+	for room := range client.rooms {
+		members := s.rooms[room]
+		if members[client.ID] != client {
+			continue
+		}
+		delete(members, client.ID)
+		if len(members) == 0 {
+			delete(s.rooms, room)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcastToNonMembers(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientIDFunc = func(r *http.Request) string { return r.URL.Query().Get("id") }
+	server := NewServerWithConfig(config)
+
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for _, id := range []string{"member-1", "member-2", "outsider-1", "outsider-2", "leaver"} {
+		recorders[id] = httptest.NewRecorder()
+		go server.HandleSSE(recorders[id], httptest.NewRequest("GET", "/events?id="+id, http.NoBody))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, id := range []string{"member-1", "member-2", "leaver"} {
+		if err := server.JoinRoom(id, "lobby"); err != nil {
+			t.Fatalf("JoinRoom(%s) failed: %v", id, err)
+		}
+	}
+	if err := server.JoinRoom("outsider-1", "other"); err != nil {
+		t.Fatalf("JoinRoom failed: %v", err)
+	}
+	if err := server.LeaveRoom("leaver", "lobby"); err != nil {
+		t.Fatalf("LeaveRoom failed: %v", err)
+	}
+
+	matched := server.BroadcastToNonMembers("lobby", Event{Type: "discover", Data: "join the lobby"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if matched != 3 {
+		t.Errorf("Expected 3 non-members, got %d", matched)
+	}
+	for id, w := range recorders {
+		got := strings.Contains(w.Body.String(), "join the lobby")
+		if want := !strings.HasPrefix(id, "member-"); got != want {
+			t.Errorf("Expected %s delivery=%v, got %v", id, want, got)
+		}
+	}
+}
+
+func TestBroadcastToRoom(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientIDFunc = func(r *http.Request) string { return r.URL.Query().Get("id") }
+	server := NewServerWithConfig(config)
+
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for _, id := range []string{"member-1", "member-2", "outsider", "leaver"} {
+		recorders[id] = httptest.NewRecorder()
+		go server.HandleSSE(recorders[id], httptest.NewRequest("GET", "/events?id="+id, http.NoBody))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, id := range []string{"member-1", "member-2", "leaver"} {
+		if err := server.JoinRoom(id, "lobby"); err != nil {
+			t.Fatalf("JoinRoom(%s) failed: %v", id, err)
+		}
+	}
+	if err := server.JoinRoom("outsider", "other"); err != nil {
+		t.Fatalf("JoinRoom failed: %v", err)
+	}
+	if err := server.LeaveRoom("leaver", "lobby"); err != nil {
+		t.Fatalf("LeaveRoom failed: %v", err)
+	}
+
+	if rooms := server.Rooms(); len(rooms) != 2 || rooms[0] != "lobby" || rooms[1] != "other" {
+		t.Errorf("Expected rooms [lobby other], got %v", rooms)
+	}
+
+	matched := server.BroadcastToRoom("lobby", Event{Type: "chat", Data: "hello lobby"})
+	if empty := server.BroadcastToRoom("empty", Event{Type: "chat", Data: "nobody"}); empty != 0 {
+		t.Errorf("Expected no recipients for an empty room, got %d", empty)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if matched != 2 {
+		t.Errorf("Expected 2 members, got %d", matched)
+	}
+	for id, w := range recorders {
+		got := strings.Contains(w.Body.String(), "hello lobby")
+		if want := strings.HasPrefix(id, "member-"); got != want {
+			t.Errorf("Expected %s delivery=%v, got %v", id, want, got)
+		}
+	}
+}
+
+func TestRoomMembershipErrorsAndCleanup(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if err := server.JoinRoom("ghost", "lobby"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound joining, got %v", err)
+	}
+	if err := server.LeaveRoom("ghost", "lobby"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound leaving, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		client := addBareClient(server, fmt.Sprintf("c%d", i), 1)
+		if err := server.JoinRoom(client.ID, "lobby"); err != nil {
+			t.Fatalf("JoinRoom failed: %v", err)
+		}
+		server.disconnectClient(client, "")
+	}
+
+	server.mu.RLock()
+	rooms := len(server.rooms)
+	server.mu.RUnlock()
+	if rooms != 0 {
+		t.Errorf("Expected rooms to be removed with their last member, got %d", rooms)
+	}
+}
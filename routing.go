@@ -0,0 +1,34 @@
+package sse
+
+// Routing decisions written by Config.DebugRouting
+const (
+	routeAll    = "broadcast-all"
+	routeFunc   = "func"
+	routeDirect = "direct"
+	routeReplay = "replay"
+)
+
+// routing returns why event reached its client: the route set by the
+// method that sent it, or the subscription type for BroadcastToType
+func (e Event) routing() string {
+	if e.route != "" {
+		return e.route
+	}
+	if e.target != "" {
+		return "type:" + e.target
+	}
+	return ""
+}
+
+// routingComment returns the comment line explaining event's routing when
+// Config.DebugRouting is set, or an empty string
+func (s *Server) routingComment(event Event) string {
+	if !s.config.DebugRouting {
+		return ""
+	}
+	route := event.routing()
+	if route == "" {
+		return ""
+	}
+	return ": matched-subscription=" + sanitizeLine(route) + "\n"
+}
@@ -0,0 +1,94 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugRouting(t *testing.T) {
+	config := DefaultConfig()
+	config.DebugRouting = true
+	config.IdentityFunc = func(*http.Request) string { return "alice" }
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events?types=chat", http.NoBody))
+	time.Sleep(50 * time.Millisecond)
+
+	if err := server.JoinRoom("c1", "lobby"); err != nil {
+		t.Fatalf("JoinRoom failed: %v", err)
+	}
+	server.Broadcast(Event{Type: "news", Data: "to-all"})
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "to-type"})
+	server.BroadcastToRoom("lobby", Event{Type: "room", Data: "to-room"})
+	server.BroadcastToIdentity("alice", Event{Type: "user", Data: "to-identity"})
+	if err := server.SendToClient("c1", Event{Type: "reply", Data: "to-client"}); err != nil {
+		t.Fatalf("SendToClient failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	for _, want := range []string{
+		": matched-subscription=broadcast-all\nevent: news\ndata: to-all\n",
+		": matched-subscription=type:chat\nevent: chat\ndata: to-type\n",
+		": matched-subscription=room:lobby\nevent: room\ndata: to-room\n",
+		": matched-subscription=identity:alice\nevent: user\ndata: to-identity\n",
+		": matched-subscription=direct\nevent: reply\ndata: to-client\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in %q", want, body)
+		}
+	}
+	if strings.Contains(body, "matched-subscription=broadcast-all\nevent: connection") {
+		t.Error("Expected no routing comment on the connection event")
+	}
+}
+
+func TestDebugRoutingDataOnly(t *testing.T) {
+	config := DefaultConfig()
+	config.DebugRouting = true
+	config.DataOnly = true
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events?types=chat", http.NoBody))
+	time.Sleep(50 * time.Millisecond)
+
+	server.Broadcast(Event{Type: "news", Data: "to-all"})
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "to-type"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	for _, want := range []string{
+		": matched-subscription=broadcast-all\ndata: {\"type\":\"news\",\"data\":\"to-all\"}\n",
+		": matched-subscription=type:chat\ndata: {\"type\":\"chat\",\"data\":\"to-type\"}\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in %q", want, body)
+		}
+	}
+}
+
+func TestDebugRoutingDisabled(t *testing.T) {
+	server := NewServer()
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events?types=chat", http.NoBody))
+	time.Sleep(50 * time.Millisecond)
+
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "to-type"})
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	if strings.Contains(w.Body.String(), "matched-subscription") {
+		t.Errorf("Expected no routing comments by default, got %q", w.Body.String())
+	}
+}
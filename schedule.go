@@ -0,0 +1,86 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// scheduler tracks pending scheduled broadcasts so they can be canceled
+// individually or all at once on shutdown
+type scheduler struct {
+	mu      sync.Mutex
+	next    uint64
+	timers  map[uint64]*time.Timer
+	stopped bool
+}
+
+// newScheduler creates an empty scheduler
+func newScheduler() *scheduler {
+	return &scheduler{timers: make(map[uint64]*time.Timer)}
+}
+
+// add runs fn after delay unless canceled, returning the cancel function
+func (sc *scheduler) add(delay time.Duration, fn func()) func() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.stopped {
+		return func() {}
+	}
+
+	id := sc.next
+	sc.next++
+	sc.timers[id] = time.AfterFunc(delay, func() {
+		// A canceled timer may already be running; only fire if still pending
+		if sc.remove(id) {
+			fn()
+		}
+	})
+
+	return func() {
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		if timer, ok := sc.timers[id]; ok {
+			timer.Stop()
+			delete(sc.timers, id)
+		}
+	}
+}
+
+// remove forgets a pending timer and reports whether it was still pending
+func (sc *scheduler) remove(id uint64) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	_, ok := sc.timers[id]
+	delete(sc.timers, id)
+	return ok
+}
+
+// pending returns the number of scheduled broadcasts yet to fire
+func (sc *scheduler) pending() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.timers)
+}
+
+// stop cancels every pending timer and rejects new ones
+func (sc *scheduler) stop() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.stopped = true
+	for id, timer := range sc.timers {
+		timer.Stop()
+		delete(sc.timers, id)
+	}
+}
+
+// ScheduleBroadcast broadcasts event at the given time unless the returned
+// cancel function is called first. Times in the past fire immediately.
+// Pending broadcasts are discarded on Shutdown.
+func (s *Server) ScheduleBroadcast(at time.Time, event Event) (cancel func()) {
+	return s.scheduled.add(time.Until(at), func() {
+		s.Broadcast(event)
+	})
+}
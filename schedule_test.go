@@ -0,0 +1,64 @@
+package sse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleBroadcastFires(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "c1", 8)
+	server.ScheduleBroadcast(time.Now().Add(50*time.Millisecond), Event{Type: "notice", Data: "maintenance"})
+
+	select {
+	case event := <-client.EventCh:
+		if event.Type != "notice" {
+			t.Errorf("Expected scheduled notice, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Scheduled broadcast never fired")
+	}
+
+	if pending := server.scheduled.pending(); pending != 0 {
+		t.Errorf("Expected no pending broadcasts after firing, got %d", pending)
+	}
+}
+
+func TestScheduleBroadcastCancel(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "c1", 8)
+	cancel := server.ScheduleBroadcast(time.Now().Add(50*time.Millisecond), Event{Type: "notice", Data: "maintenance"})
+	cancel()
+	cancel() // canceling twice is harmless
+
+	select {
+	case event := <-client.EventCh:
+		t.Errorf("Expected canceled broadcast not to fire, got %+v", event)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	if pending := server.scheduled.pending(); pending != 0 {
+		t.Errorf("Expected no pending broadcasts after cancel, got %d", pending)
+	}
+}
+
+func TestScheduleBroadcastClearedOnShutdown(t *testing.T) {
+	server := NewServer()
+
+	server.ScheduleBroadcast(time.Now().Add(time.Hour), Event{Type: "notice"})
+	server.ScheduleBroadcast(time.Now().Add(time.Hour), Event{Type: "notice"})
+	server.Shutdown()
+
+	if pending := server.scheduled.pending(); pending != 0 {
+		t.Errorf("Expected pending broadcasts cleared on shutdown, got %d", pending)
+	}
+
+	server.ScheduleBroadcast(time.Now(), Event{Type: "notice"})()
+	if pending := server.scheduled.pending(); pending != 0 {
+		t.Errorf("Expected no broadcasts scheduled after shutdown, got %d", pending)
+	}
+}
@@ -0,0 +1,108 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrSchemaMismatch is returned when event data does not match the schema
+// registered for its type
+var ErrSchemaMismatch = errors.New("sse: event data does not match schema")
+
+// eventSchema is the struct type registered for an event type and the JSON
+// names of its fields tagged `sse:"required"`
+type eventSchema struct {
+	typ      reflect.Type
+	required []string
+}
+
+// RegisterEventType registers a struct value, or a pointer to one, as the
+// schema for events of type name. Broadcasts of that type are validated
+// before delivery: data must decode into the struct without unknown fields
+// or mismatched types, and fields tagged `sse:"required"` must be present.
+// Data of the schema's own Go type is always accepted. Non-conforming
+// broadcasts are dropped and logged; use ValidateEvent to get the error.
+// Registering a name again replaces its schema. It panics if schema is not
+// a struct.
+func (s *Server) RegisterEventType(name string, schema interface{}) {
+	typ := reflect.TypeOf(schema)
+	if typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sse: schema for event type %q must be a struct, got %T", name, schema))
+	}
+
+	s.schemas.Store(name, &eventSchema{typ: typ, required: requiredFields(typ)})
+}
+
+// ValidateEvent checks event data against the schema registered for its
+// type, returning an error wrapping ErrSchemaMismatch that describes the
+// mismatch. Events of unregistered types are always valid.
+func (s *Server) ValidateEvent(event Event) error {
+	value, ok := s.schemas.Load(event.Type)
+	if !ok {
+		return nil
+	}
+	return value.(*eventSchema).validate(event)
+}
+
+// validateBroadcast reports whether event may be broadcast, logging why not
+func (s *Server) validateBroadcast(event Event) bool {
+	if err := s.ValidateEvent(event); err != nil {
+		s.logger.Warnf("sse: dropping broadcast: %v", err)
+		return false
+	}
+	return true
+}
+
+// validate checks event data against the schema
+func (es *eventSchema) validate(event Event) error {
+	dataType := reflect.TypeOf(event.Data)
+	if dataType == es.typ || (dataType != nil && dataType.Kind() == reflect.Pointer && dataType.Elem() == es.typ) {
+		return nil
+	}
+
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSchemaMismatch, event.Type, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(reflect.New(es.typ).Interface()); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSchemaMismatch, event.Type, err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSchemaMismatch, event.Type, err)
+	}
+	for _, name := range es.required {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("%w: %s: missing required field %q", ErrSchemaMismatch, event.Type, name)
+		}
+	}
+	return nil
+}
+
+// requiredFields returns the JSON names of the fields of typ tagged `sse:"required"`
+func requiredFields(typ reflect.Type) []string {
+	var required []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("sse") != "required" {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			name = tag
+		}
+		required = append(required, name)
+	}
+	return required
+}
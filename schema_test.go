@@ -0,0 +1,90 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type chatMessage struct {
+	User string `json:"user" sse:"required"`
+	Text string `json:"text" sse:"required"`
+	Room string `json:"room,omitempty"`
+}
+
+func TestRegisterEventTypeValidation(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+	server.RegisterEventType("chat", chatMessage{})
+
+	tests := []struct {
+		name    string
+		data    interface{}
+		wantErr string
+	}{
+		{"schema type", chatMessage{User: "ana", Text: "hi"}, ""},
+		{"schema pointer", &chatMessage{User: "ana", Text: "hi"}, ""},
+		{"conforming map", map[string]interface{}{"user": "ana", "text": "hi", "room": "lobby"}, ""},
+		{"unknown field", map[string]interface{}{"user": "ana", "text": "hi", "color": "red"}, `unknown field "color"`},
+		{"wrong type", map[string]interface{}{"user": 42, "text": "hi"}, "cannot unmarshal number"},
+		{"missing required", map[string]interface{}{"user": "ana"}, `missing required field "text"`},
+		{"not an object", "hello", "cannot unmarshal string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.ValidateEvent(Event{Type: "chat", Data: tt.data})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected valid data, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSchemaMismatch) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected ErrSchemaMismatch mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if err := server.ValidateEvent(Event{Type: "other", Data: 1}); err != nil {
+		t.Errorf("Expected unregistered types to be valid, got %v", err)
+	}
+}
+
+func TestBroadcastRejectsSchemaMismatch(t *testing.T) {
+	server := NewServer()
+	server.RegisterEventType("chat", &chatMessage{})
+
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+
+	server.Broadcast(Event{Type: "chat", Data: map[string]string{"user": "ana", "text": "conforming"}})
+	server.Broadcast(Event{Type: "chat", Data: map[string]string{"user": "bob", "body": "rejected"}})
+
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	if !strings.Contains(body, "conforming") {
+		t.Error("Expected the conforming event to be delivered")
+	}
+	if strings.Contains(body, "rejected") {
+		t.Error("Expected the non-conforming event to be dropped")
+	}
+}
+
+func TestRegisterEventTypeRequiresStruct(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a non-struct schema to panic")
+		}
+	}()
+	server.RegisterEventType("bad", "not a struct")
+}
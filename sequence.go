@@ -0,0 +1,61 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrReservedMeta is returned by BroadcastSequence when an event's Meta
+// already uses one of the keys it writes
+var ErrReservedMeta = errors.New("sse: event meta uses a reserved key")
+
+// sequenceMetaKeys are the Meta keys BroadcastSequence writes, in the order
+// their comments lead each event
+var sequenceMetaKeys = []string{"total", "index"}
+
+// BroadcastSequence broadcasts a bounded run of events, such as a bulk
+// export, annotated for progress display. Every event carries an
+// ": index=N" comment counting from 1, and the first also carries
+// ": total=N" with the length of the run, ahead of its index. Both lead the
+// event's other Meta comments. The run is throttled as a single broadcast,
+// and slice data is not split as with Config.SplitSliceData, so the counts
+// always match what is sent. If any event's Meta already has an "index" or
+// "total" key, nothing is sent and ErrReservedMeta is returned.
+func (s *Server) BroadcastSequence(events []Event) error {
+	for i, event := range events {
+		for _, key := range sequenceMetaKeys {
+			if _, ok := event.Meta[key]; ok {
+				return fmt.Errorf("%w: event %d has %q", ErrReservedMeta, i, key)
+			}
+		}
+	}
+	if len(events) == 0 || !s.throttleBroadcast() {
+		return nil
+	}
+
+	total := strconv.Itoa(len(events))
+	for i, event := range events {
+		meta := make(map[string]string, len(event.Meta)+2)
+		for k, v := range event.Meta {
+			meta[k] = v
+		}
+		meta["index"] = strconv.Itoa(i + 1)
+		if i == 0 {
+			meta["total"] = total
+		}
+		event.Meta = meta
+		event.sequenced = true
+
+		s.broadcastTo(event, nil, true)
+	}
+	return nil
+}
+
+// leadingMeta returns the Meta keys written ahead of the others, in order
+func (e Event) leadingMeta() []string {
+	if e.sequenced {
+		return sequenceMetaKeys
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcastSequenceProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataOnly bool
+		want     string
+	}{
+		{
+			"typed", false,
+			": total=3\n: index=1\nevent: record\ndata: row-0\n\n" +
+				": index=2\n: trace=t1\nevent: record\ndata: row-1\n\n" +
+				": index=3\nevent: record\ndata: row-2\n\n",
+		},
+		{
+			"data only", true,
+			": total=3\n: index=1\ndata: {\"type\":\"record\",\"data\":\"row-0\"}\n\n" +
+				": index=2\n: trace=t1\ndata: {\"type\":\"record\",\"data\":\"row-1\"}\n\n" +
+				": index=3\ndata: {\"type\":\"record\",\"data\":\"row-2\"}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.DataOnly = tt.dataOnly
+			server := NewServerWithConfig(config)
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			w := httptest.NewRecorder()
+			go server.HandleSSE(w, req)
+			time.Sleep(100 * time.Millisecond)
+
+			events := make([]Event, 3)
+			for i := range events {
+				events[i] = Event{Type: "record", Data: fmt.Sprintf("row-%d", i)}
+			}
+			events[1].Meta = map[string]string{"trace": "t1"}
+			if err := server.BroadcastSequence(events); err != nil {
+				t.Fatalf("Expected the sequence to be sent, got %v", err)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			server.Shutdown()
+
+			body := w.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("Expected %q in response, got %q", tt.want, body)
+			}
+			if count := strings.Count(body, ": total="); count != 1 {
+				t.Errorf("Expected a single total comment, got %d", count)
+			}
+			if events[1].Meta["index"] != "" {
+				t.Error("Expected caller's Meta map to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestBroadcastSequenceRejectsReservedMeta(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	events, unsubscribe := server.Subscribe(context.Background())
+	defer unsubscribe()
+
+	for _, key := range []string{"index", "total"} {
+		run := []Event{
+			{Type: "record", Data: "row-0"},
+			{Type: "record", Data: "row-1", Meta: map[string]string{key: "mine"}},
+		}
+		if err := server.BroadcastSequence(run); !errors.Is(err, ErrReservedMeta) {
+			t.Errorf("Expected ErrReservedMeta for %q, got %v", key, err)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected nothing to be sent, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
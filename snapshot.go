@@ -0,0 +1,19 @@
+package sse
+
+import "net/http"
+
+// sendSnapshot writes the "snapshot" event built by Config.SnapshotFunc, if
+// set. The client is registered first, so updates made while the snapshot
+// is built are queued and streamed after it.
+func (s *Server) sendSnapshot(client *Client, r *http.Request) error {
+	if s.config.SnapshotFunc == nil {
+		return nil
+	}
+
+	items := s.config.SnapshotFunc(r)
+	if items == nil {
+		// Encode an empty collection as [] rather than null
+		items = []interface{}{}
+	}
+	return s.sendEventToClient(client, Event{Type: "snapshot", Data: items})
+}
@@ -0,0 +1,58 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotPrecedesDeltas(t *testing.T) {
+	config := DefaultConfig()
+	var server *Server
+	config.SnapshotFunc = func(r *http.Request) []interface{} {
+		// An update landing while the snapshot is built is streamed after it
+		server.Broadcast(Event{Type: "delta", Data: "added-c"})
+		return []interface{}{"a", "b"}
+	}
+	server = NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Broadcast(Event{Type: "delta", Data: "removed-a"})
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	snapshot := strings.Index(body, "event: snapshot\ndata: [\"a\",\"b\"]\n\n")
+	first := strings.Index(body, "event: delta\ndata: added-c\n\n")
+	second := strings.Index(body, "event: delta\ndata: removed-a\n\n")
+
+	if snapshot < 0 || first < 0 || second < 0 {
+		t.Fatalf("Expected snapshot and both deltas, got %q", body)
+	}
+	if !(snapshot < first && first < second) {
+		t.Errorf("Expected snapshot before deltas in order, got %q", body)
+	}
+}
+
+func TestEmptySnapshot(t *testing.T) {
+	config := DefaultConfig()
+	config.SnapshotFunc = func(r *http.Request) []interface{} { return nil }
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if body := w.Body.String(); !strings.Contains(body, "event: snapshot\ndata: []\n\n") {
+		t.Errorf("Expected empty snapshot array, got %q", body)
+	}
+}
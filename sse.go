@@ -4,27 +4,151 @@
 package sse
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+)
+
+// ErrEventTooLarge is returned when an event's encoded data exceeds Config.MaxEventBytes
+var ErrEventTooLarge = errors.New("sse: event data exceeds maximum size")
+
+// ErrMarshalFailed is returned when event data cannot be encoded as JSON
+var ErrMarshalFailed = errors.New("sse: event data could not be marshaled")
+
+// MarshalFallback decides how events whose data fails to marshal are handled
+type MarshalFallback int
+
+const (
+	// MarshalFallbackSanitize sends a single-line %v rendering of the data
+	MarshalFallbackSanitize MarshalFallback = iota
+	// MarshalFallbackSkip drops the event for that client
+	MarshalFallbackSkip
+)
+
+// ErrInvalidConfig is returned by Config.Validate for unusable settings
+var ErrInvalidConfig = errors.New("sse: invalid config")
+
+// errClientClosed is returned when sending to a client that has been closed
+var errClientClosed = errors.New("client connection closed")
+
+// Reasons carried by the terminal close event, see Config.SendCloseEvent
+const (
+	CloseReasonShutdown     = "shutdown"
+	CloseReasonSlowConsumer = "slow_consumer"
+	CloseReasonBufferLimit  = "buffer_limit"
+	CloseReasonReplaced     = "replaced"
+	CloseReasonClosed       = "closed"
+)
+
+// DuplicateIDPolicy decides what happens when a connection reuses the ID of
+// an active client, which can only occur with Config.ClientIDFunc
+type DuplicateIDPolicy int
+
+const (
+	// DuplicateRejectNew keeps the existing connection and rejects the new one with 409
+	DuplicateRejectNew DuplicateIDPolicy = iota
+	// DuplicateReplaceOld closes the existing connection and registers the new one
+	DuplicateReplaceOld
 )
 
 // Event represents a Server-Sent Event
 type Event struct {
-	Type string      `json:"type,omitempty"`
-	Data interface{} `json:"data"`
-	ID   string      `json:"id,omitempty"`
+	Type string            `json:"type,omitempty"`
+	Data interface{}       `json:"data"`
+	ID   string            `json:"id,omitempty"`
+	Meta map[string]string `json:"meta,omitempty"` // written as ": key=value" comment lines, ordered by key after BroadcastSequence progress
+
+	tracker   *deliveryTracker // set by BroadcastTracked
+	target    string           // subscription type for BroadcastToType, empty for every client
+	retry     int              // reconnection delay in milliseconds written as a retry field, 0 omits it
+	ctx       context.Context  // set by BroadcastCtx for Config.EventMiddleware, cleared before publishing
+	route     string           // why the event was sent, written by Config.DebugRouting; see routing
+	waiter    *deadlineWaiter  // set by BroadcastDeadline
+	sequenced bool             // set by BroadcastSequence, whose total and index comments lead the Meta
+	generic   *genericData     // set by fan-out, shared by every client's copy; see normalizedData
 }
 
 // Config holds the configuration for the SSE server
 type Config struct {
-	MaxConnections    int           `json:"max_connections"`
-	RetryTimeout      int           `json:"retry_timeout"` // milliseconds
-	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
-	BufferSize        int           `json:"buffer_size"`
+	MaxConnections             int                                                   `json:"max_connections"`
+	RetryTimeout               int                                                   `json:"retry_timeout"` // milliseconds
+	HeartbeatInterval          time.Duration                                         `json:"heartbeat_interval"`
+	BufferSize                 int                                                   `json:"buffer_size"`
+	MaxEventBytes              int                                                   `json:"max_event_bytes"`           // 0 means unlimited
+	MaxBroadcastsPerSecond     int                                                   `json:"max_broadcasts_per_second"` // server-wide, 0 means unlimited
+	ThrottlePolicy             ThrottlePolicy                                        `json:"throttle_policy"`
+	MarshalFallback            MarshalFallback                                       `json:"marshal_fallback"`
+	SplitSliceData             bool                                                  `json:"split_slice_data"`   // send each slice element as its own event
+	Encoders                   map[string]Encoder                                    `json:"-"`                  // per-connection encodings keyed by negotiated name
+	MaxTotalBuffered           int                                                   `json:"max_total_buffered"` // queued events across all clients, 0 means unlimited
+	BufferPolicy               BufferPolicy                                          `json:"buffer_policy"`
+	SendCloseEvent             bool                                                  `json:"send_close_event"` // send a `close` event before server-initiated disconnects
+	IdentityFunc               func(r *http.Request) string                          `json:"-"`                // derives the user identity of a connection
+	HistorySize                int                                                   `json:"history_size"`     // broadcasts retained for replay, including Last-Event-ID resumes; 0 disables history
+	ClientIDFunc               func(r *http.Request) string                          `json:"-"`                // custom client IDs, empty results fall back to generated IDs
+	DuplicateIDPolicy          DuplicateIDPolicy                                     `json:"duplicate_id_policy"`
+	ReconnectWindow            time.Duration                                         `json:"reconnect_window"`              // window for ReconnectRate, defaults to 5 minutes
+	CoalesceHeartbeat          bool                                                  `json:"coalesce_heartbeat"`            // skip heartbeats for clients with recent or pending events
+	CursorCookieName           string                                                `json:"cursor_cookie_name"`            // cookie carrying the last event ID when the header is absent
+	PrettyJSON                 bool                                                  `json:"pretty_json"`                   // indent JSON data across multiple data lines, for debugging
+	OccupancySampleInterval    time.Duration                                         `json:"occupancy_sample_interval"`     // buffer occupancy sampling period, 0 disables sampling
+	FlushInterval              time.Duration                                         `json:"flush_interval"`                // coalesce writes and flush at most this often, 0 flushes every event
+	OnConnect                  func(*ClientInfo)                                     `json:"-"`                             // called without locks once a client is registered and replayed, may broadcast
+	OnDisconnect               func(clientID string)                                 `json:"-"`                             // called without locks once a connected client is removed, not for one replaced under the same ID
+	PerTypeBuffers             bool                                                  `json:"per_type_buffers"`              // queue each event type separately per client and drain them round-robin
+	MaxTypeLanes               int                                                   `json:"max_type_lanes"`                // per-type queues per client with PerTypeBuffers, later types share the last; 0 means 8
+	AllowedOrigins             []string                                              `json:"allowed_origins"`               // CORS allowlist, empty allows any origin; see SetAllowedOrigins
+	ReplayWindow               int                                                   `json:"replay_window"`                 // events replayed between checks of the live queue, defaults to BufferSize
+	AutoEventID                bool                                                  `json:"auto_event_id"`                 // give broadcasts without an ID the next sequence number
+	IDFormat                   func(seq uint64) string                               `json:"-"`                             // renders AutoEventID sequence numbers, decimal by default
+	ShardCount                 int                                                   `json:"shard_count"`                   // split broadcast fan-out into this many parallel shards, 0 or 1 fans out sequentially
+	OnRawConn                  func(conn net.Conn)                                   `json:"-"`                             // tunes the socket of each connection, needs ConnContext on the http.Server
+	SnapshotFunc               func(r *http.Request) []interface{}                   `json:"-"`                             // builds the array sent as a "snapshot" event right after the connection event
+	AcceptRatePerSecond        int                                                   `json:"accept_rate_per_second"`        // new connections admitted per second, 0 means unlimited
+	AcceptBurst                int                                                   `json:"accept_burst"`                  // connections admitted at once, defaults to AcceptRatePerSecond
+	AcceptPolicy               ThrottlePolicy                                        `json:"accept_policy"`                 // ThrottleDrop answers excess connections with 503, ThrottleDelay queues them
+	DataOnly                   bool                                                  `json:"data_only"`                     // omit event and id fields, embedding type and id in a JSON data envelope
+	MemoryBudget               int64                                                 `json:"memory_budget"`                 // estimated bytes for all client buffers, BufferSize is clamped to fit; 0 means unlimited
+	ResumeTokenTTL             time.Duration                                         `json:"resume_token_ttl"`              // how long a resume token outlives its connection; 0 disables resume tokens
+	ReconnectHintHeaders       bool                                                  `json:"reconnect_hint_headers"`        // send X-SSE-Retry and X-SSE-Heartbeat-Interval response headers
+	FlushTimeout               time.Duration                                         `json:"flush_timeout"`                 // write deadline for each flush, a timed-out flush disconnects the client; 0 disables
+	AttributesFunc             func(r *http.Request) map[string]string               `json:"-"`                             // derives labels such as tenant for a connection, see ConnectionCountByLabel
+	CheckpointInterval         time.Duration                                         `json:"checkpoint_interval"`           // period of checkpoint events carrying the latest AutoEventID sequence; 0 disables them
+	BeforeSend                 func(clientID string, ev Event) Event                 `json:"-"`                             // last-mile per-connection rewrite of every event before it is written
+	MaxSubscriptionHeaderBytes int                                                   `json:"max_subscription_header_bytes"` // longest accepted types query parameter, larger is refused with 431; 0 is unlimited
+	EventMiddleware            func(ctx context.Context, event Event) Event          `json:"-"`                             // rewrites each published broadcast once before it is recorded and sent; ctx comes from BroadcastCtx
+	DropAfterFullFor           time.Duration                                         `json:"drop_after_full_for"`           // how long a buffer must stay full before the client is dropped as a slow consumer; 0 drops on the first full buffer
+	LegacyBrowserCompat        bool                                                  `json:"legacy_browser_compat"`         // lead the stream with a UTF-8 BOM and send X-Content-Type-Options: nosniff for legacy IE/Edge
+	Logger                     Logger                                                `json:"-"`                             // diagnostic output; nil discards it, StdLogger{} sends warnings and errors to the standard log package
+	NotifyThrottling           bool                                                  `json:"notify_throttling"`             // send clients a debounced "throttled" event counting broadcasts dropped by MaxBroadcastsPerSecond
+	OrderedBroadcasts          bool                                                  `json:"ordered_broadcasts"`            // deliver broadcasts one at a time from a single goroutine so all clients see the same order
+	OverflowPolicy             OverflowPolicy                                        `json:"overflow_policy"`               // what a broadcast does for a client whose buffer is full, disconnecting it by default
+	BlockTimeout               time.Duration                                         `json:"block_timeout"`                 // how long OverflowBlock waits for room before dropping the client as a slow consumer; 0 means 1s
+	ResumeStore                ResumeStore                                           `json:"-"`                             // where resume tokens are kept, nil keeps them in memory; share one across instances to resume on any of them
+	Authorize                  func(r *http.Request) (clientID string, allowed bool) `json:"-"`                             // gates each connection, refusing it with 401 when not allowed; a non-empty clientID overrides ClientIDFunc
+	DebugRouting               bool                                                  `json:"debug_routing"`                 // precede each routed event with a ": matched-subscription=<reason>" comment, for integration tests
+	AnnounceCapabilities       bool                                                  `json:"announce_capabilities"`         // add the negotiated encoding, compression, heartbeat and retry to the connection event
+	EnableCompression          bool                                                  `json:"enable_compression"`            // gzip the stream for clients whose Accept-Encoding allows it
+	MaxEventsPerSecond         int                                                   `json:"max_events_per_second"`         // per-connection delivery cap, excess waits in the client buffer under OverflowPolicy; 0 means unlimited
+	RejectHTTP10               bool                                                  `json:"reject_http10"`                 // refuse HTTP/1.0 requests with 505 instead of streaming until the connection closes
 }
 
 // DefaultConfig returns the default configuration
@@ -37,26 +161,116 @@ func DefaultConfig() Config {
 	}
 }
 
+// Validate reports settings the server cannot work with. BufferSize sizes
+// only each client's channel and HistorySize only the replay history; the
+// two are independent, so a small buffer does not limit replay.
+func (c Config) Validate() error {
+	if c.MaxConnections < 1 {
+		return fmt.Errorf("%w: MaxConnections must be at least 1", ErrInvalidConfig)
+	}
+	if c.BufferSize < 1 {
+		return fmt.Errorf("%w: BufferSize must be at least 1", ErrInvalidConfig)
+	}
+	if c.HistorySize < 0 {
+		return fmt.Errorf("%w: HistorySize must not be negative", ErrInvalidConfig)
+	}
+	if c.MaxTypeLanes < 0 {
+		return fmt.Errorf("%w: MaxTypeLanes must not be negative", ErrInvalidConfig)
+	}
+	if c.BlockTimeout < 0 {
+		return fmt.Errorf("%w: BlockTimeout must not be negative", ErrInvalidConfig)
+	}
+	if c.MaxSubscriptionHeaderBytes < 0 {
+		return fmt.Errorf("%w: MaxSubscriptionHeaderBytes must not be negative", ErrInvalidConfig)
+	}
+	if c.HeartbeatInterval <= 0 {
+		return fmt.Errorf("%w: HeartbeatInterval must be positive", ErrInvalidConfig)
+	}
+	if c.exceedsMemoryBudget() {
+		return fmt.Errorf("%w: BufferSize %d with MaxConnections %d needs about %d bytes, over MemoryBudget %d",
+			ErrInvalidConfig, c.BufferSize, c.MaxConnections, c.estimatedBufferBytes(), c.MemoryBudget)
+	}
+	return nil
+}
+
 // Client represents a connected SSE client
 type Client struct {
-	ID      string
-	EventCh chan Event
-	Type    string
-	conn    http.ResponseWriter
-	mu      sync.Mutex
-	closed  bool
-	server  *Server
+	ID           string
+	EventCh      chan Event
+	Type         string            // first event type subscribed to, see BroadcastToType
+	Identity     string            // set from Config.IdentityFunc at accept time
+	Version      string            // set from the X-Client-Version header at accept time, see BroadcastToVersion
+	Attributes   map[string]string // set from Config.AttributesFunc at accept time, must not be modified
+	conn         http.ResponseWriter
+	flusher      *http.ResponseController
+	out          *bufio.Writer // coalesces writes when Config.FlushInterval is set
+	lanes        *typeLanes    // per-type queues when Config.PerTypeBuffers is set
+	priority     chan Event    // targeted events delivered ahead of queued broadcasts, see SendPriority
+	replaying    bool          // guarded by Server.mu, history broadcasts are caught up by replay
+	replayHeld   bool          // counted in Server.replays until its replay ends, owned by the handler
+	replayed     uint64        // history sequence number replay has reached
+	mu           sync.Mutex
+	sendMu       sync.RWMutex  // guards sends on EventCh against close
+	closed       bool          // written with both mu and sendMu held
+	closingMu    sync.Mutex    // guards closing and closeStarted
+	closing      chan struct{} // created on demand and closed as soon as close starts; see closedSignal
+	closeStarted bool          // set once close begins, guarded by closingMu
+	reason       string        // why the server closed the client, empty if it did not
+	closeSent    bool          // whether the close event has been written
+	lastSend     atomic.Int64  // unix nanoseconds of the last successful write
+	fullSince    atomic.Int64  // unix nanoseconds a broadcast first found the buffer full, 0 once one fits again
+	connected    time.Time
+	server       *Server
+	filter       *eventFilter    // nil delivers every event
+	types        []string        // guarded by Server.mu, event types subscribed to; replaced, never modified in place
+	rooms        map[string]bool // guarded by Server.mu, rooms joined, see JoinRoom
+	encoder      Encoder         // nil uses the default JSON encoding
+	encoding     string          // Config.Encoders key of encoder, empty for JSON
+	limiter      *rateLimiter    // nil unless Config.MaxEventsPerSecond is set
+	resumeToken  string          // empty when resume tokens are disabled
+	lastEventID  string          // guarded by mu, ID of the last event written, or the resumed position
+	preamble     string          // guarded by mu, written ahead of the first event
 }
 
 // Server represents the SSE server
 type Server struct {
-	config        Config
-	clients       map[string]*Client
-	clientsByType map[string]map[string]*Client
-	mu            sync.RWMutex
-	shutdown      chan struct{}
-	ctx           context.Context
-	cancel        context.CancelFunc
+	config           Config
+	clients          map[string]*Client
+	clientsByType    map[string]map[string]*Client
+	mu               sync.RWMutex
+	rooms            map[string]map[string]*Client // room members by room name, see JoinRoom
+	shutdown         chan struct{}
+	handlers         sync.WaitGroup // running HandleSSE calls, see Wait
+	replays          sync.WaitGroup // in-flight history replays, see ShutdownContext
+	stopping         bool           // guarded by mu, set once ShutdownContext refuses new connections, and sends close events from then on
+	background       sync.WaitGroup // heartbeat and occupancy sampler loops
+	ctx              context.Context
+	cancel           context.CancelFunc
+	broadcastLimiter *rateLimiter          // nil when broadcasts are not throttled
+	acceptLimiter    *rateLimiter          // nil when connections are not paced
+	throttledDrops   atomic.Int64          // broadcasts dropped since the last throttled notice
+	throttleNotice   atomic.Bool           // whether a throttled notice is scheduled
+	ordered          chan orderedBroadcast // nil unless Config.OrderedBroadcasts is set
+	history          *history              // nil when history is disabled
+	webhooks         []*webhook
+	sticky           map[string]Event
+	stickyKeys       []string               // sticky keys in first-set order
+	states           map[string]interface{} // last state per key, see PublishState
+	stateMu          sync.Mutex             // serializes PublishState
+	schemas          sync.Map               // event type -> *eventSchema, see RegisterEventType
+	occupancy        *occupancySampler      // nil when sampling is disabled
+	reconnects       *reconnectTracker
+	scheduled        *scheduler
+	subscribers      map[*subscriber]struct{} // in-process consumers, see Subscribe
+	marshalFailures  atomic.Int64
+	accepted         atomic.Int64 // connections registered, see Stats
+	rejected         atomic.Int64 // connections refused at MaxConnections
+	eventsSent       atomic.Int64 // events written to clients
+	eventsDropped    atomic.Int64 // events not queued because a client's buffer was full
+	logger           Logger
+	eventSeq         atomic.Uint64             // last sequence ID assigned by Config.AutoEventID
+	origins          atomic.Pointer[originSet] // CORS allowlist, see SetAllowedOrigins
+	resumes          ResumeStore               // nil when resume tokens are disabled
 }
 
 // NewServer creates a new SSE server with default configuration
@@ -66,6 +280,7 @@ func NewServer() *Server {
 
 // NewServerWithConfig creates a new SSE server with custom configuration
 func NewServerWithConfig(config Config) *Server {
+	config = clampToBudget(config)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	server := &Server{
@@ -73,24 +288,99 @@ func NewServerWithConfig(config Config) *Server {
 		clients:       make(map[string]*Client),
 		clientsByType: make(map[string]map[string]*Client),
 		shutdown:      make(chan struct{}),
+		rooms:         make(map[string]map[string]*Client),
 		ctx:           ctx,
 		cancel:        cancel,
+		reconnects:    newReconnectTracker(config.ReconnectWindow),
+		resumes:       newResumeStore(config),
+		scheduled:     newScheduler(),
+		subscribers:   make(map[*subscriber]struct{}),
+		sticky:        make(map[string]Event),
+		states:        make(map[string]interface{}),
+		logger:        config.logger(),
+	}
+
+	if config.HistorySize > 0 {
+		server.history = newHistory(config.HistorySize)
+	}
+
+	if config.AcceptRatePerSecond > 0 {
+		server.acceptLimiter = newBurstLimiter(config.AcceptRatePerSecond, config.AcceptBurst)
+	}
+
+	if config.MaxBroadcastsPerSecond > 0 {
+		server.broadcastLimiter = newRateLimiter(config.MaxBroadcastsPerSecond)
+	}
+
+	if config.OccupancySampleInterval > 0 {
+		server.occupancy = newOccupancySampler()
+		server.background.Add(1)
+		go server.sampleOccupancy(config.OccupancySampleInterval)
+	}
+
+	if config.CheckpointInterval > 0 {
+		server.background.Add(1)
+		go server.checkpoints(config.CheckpointInterval)
+	}
+
+	if config.OrderedBroadcasts {
+		server.ordered = make(chan orderedBroadcast)
+		server.background.Add(1)
+		go server.orderBroadcasts()
 	}
 
+	server.SetAllowedOrigins(config.AllowedOrigins)
+
 	// Start heartbeat goroutine
+	server.background.Add(1)
 	go server.heartbeat()
 
 	return server
 }
 
+// Config returns a copy of the configuration the server is running with,
+// after BufferSize has been clamped to MemoryBudget and with the CORS
+// allowlist last set by SetAllowedOrigins
+func (s *Server) Config() Config {
+	config := s.config
+
+	if config.Encoders != nil {
+		config.Encoders = make(map[string]Encoder, len(s.config.Encoders))
+		for name, encoder := range s.config.Encoders {
+			config.Encoders[name] = encoder
+		}
+	}
+
+	config.AllowedOrigins = nil
+	if set := s.origins.Load(); set != nil && *set != nil {
+		for origin := range *set {
+			config.AllowedOrigins = append(config.AllowedOrigins, origin)
+		}
+		sort.Strings(config.AllowedOrigins)
+	}
+	return config
+}
+
 // HandleSSE handles incoming SSE connections
 func (s *Server) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	defer drainBody(r.Body)
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	s.setHintHeaders(w)
+	s.setLegacyHeaders(w)
+
+	if !s.applyCORS(w, r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	authorizedID, ok := s.admitRequest(w, r)
+	if !ok {
+		return
+	}
 
 	// Check if connection supports flushing
 	if _, ok := w.(http.Flusher); !ok {
@@ -98,218 +388,1471 @@ func (s *Server) HandleSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check connection limit
-	s.mu.RLock()
-	if len(s.clients) >= s.config.MaxConnections {
-		s.mu.RUnlock()
-		http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+	if s.config.OnRawConn != nil {
+		if conn := rawConn(r.Context()); conn != nil {
+			s.config.OnRawConn(conn)
+		}
+	}
+
+	if !s.trackHandler() {
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
 		return
 	}
-	s.mu.RUnlock()
+	defer s.handlers.Done()
 
-	// Create client
-	clientID := generateClientID()
-	client := &Client{
-		ID:      clientID,
-		EventCh: make(chan Event, s.config.BufferSize),
-		conn:    w,
-		server:  s,
+	if !s.throttleAccept(r) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too many connection attempts", http.StatusServiceUnavailable)
+		return
 	}
 
-	// Register client
-	s.mu.Lock()
-	s.clients[clientID] = client
-	s.mu.Unlock()
+	client, replay := s.acceptClient(w, r, authorizedID)
+	if client == nil {
+		return
+	}
+	defer client.closeCompression()
+	defer s.releaseResumeToken(client)
+	defer s.releaseReplay(client)
 
-	// Send initial connection event
+	// Send initial connection event, carrying the client's reconnection delay
 	initialEvent := Event{
-		Type: "connection",
-		Data: map[string]interface{}{
-			"client_id": clientID,
-			"timestamp": time.Now().Unix(),
-		},
+		Type:  "connection",
+		Data:  s.connectionData(client),
+		retry: s.config.RetryTimeout,
 	}
 
 	if err := s.sendEventToClient(client, initialEvent); err != nil {
-		s.removeClient(clientID)
+		s.disconnectClient(client, "")
+		return
+	}
+
+	if err := s.sendSnapshot(client, r); err != nil {
+		s.disconnectClient(client, "")
+		return
+	}
+
+	// Whatever the exit path, push out any coalesced bytes before returning
+	defer func() { _ = s.flushClient(client) }()
+
+	err := s.replay(client, replay)
+	s.releaseReplay(client)
+	if err != nil {
+		s.disconnectClient(client, "")
+		return
+	}
+
+	// The stream is established once the connection event and replay are out
+	if err := s.flushClient(client); err != nil {
+		s.disconnectClient(client, "")
+		return
+	}
+
+	// No locks are held here, so the hook may broadcast, including to this client
+	if s.config.OnConnect != nil {
+		s.config.OnConnect(client.info())
+	}
+	defer s.notifyDisconnect(client)
+
+	s.serveClient(client, r)
+}
+
+// setHintHeaders advertises the retry and heartbeat intervals in
+// milliseconds when Config.ReconnectHintHeaders is set, so clients can
+// configure their watchdogs before the first event arrives
+func (s *Server) setHintHeaders(w http.ResponseWriter) {
+	if !s.config.ReconnectHintHeaders {
+		return
+	}
+	w.Header().Set("X-SSE-Retry", strconv.Itoa(s.config.RetryTimeout))
+	w.Header().Set("X-SSE-Heartbeat-Interval", strconv.FormatInt(s.config.HeartbeatInterval.Milliseconds(), 10))
+}
+
+// utf8BOM leads the stream for legacy browsers, see Config.LegacyBrowserCompat
+const utf8BOM = "\uFEFF"
+
+// setLegacyHeaders sets the headers legacy IE/Edge need to parse the
+// stream when Config.LegacyBrowserCompat is set
+func (s *Server) setLegacyHeaders(w http.ResponseWriter) {
+	if !s.config.LegacyBrowserCompat {
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+// streamPreamble returns the bytes written ahead of a client's first event
+func (s *Server) streamPreamble() string {
+	if s.config.LegacyBrowserCompat {
+		return utf8BOM
+	}
+	return ""
+}
+
+// maxDrainBytes bounds how much of an unexpected request body is discarded
+// on teardown; a larger body is left for the server to close the connection
+const maxDrainBytes = 64 << 10
+
+// drainBody discards and closes a request body so a client that sent one on
+// the GET does not prevent connection reuse
+func drainBody(body io.ReadCloser) {
+	if body == nil {
 		return
 	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	_ = body.Close()
+}
+
+// serveClient runs the client's writer goroutine and waits for it to end,
+// disconnecting the client first if the request is canceled. The writer
+// must finish before HandleSSE returns, as the ResponseWriter is not usable
+// after that.
+func (s *Server) serveClient(client *Client, r *http.Request) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.writeLoop(client)
+	}()
+
+	select {
+	case <-done:
+	case <-r.Context().Done():
+		// Closing EventCh tells the writer to stop
+		s.disconnectClient(client, "")
+		<-done
+	}
+}
+
+// writeLoop delivers queued events to the client until it is closed by the
+// server, a write fails or the server shuts down. It owns the connection
+// while it runs.
+func (s *Server) writeLoop(client *Client) {
+	var flushTick <-chan time.Time
+	if client.out != nil {
+		ticker := time.NewTicker(s.config.FlushInterval)
+		defer ticker.Stop()
+		flushTick = ticker.C
+	}
+
+	var lanesReady <-chan struct{}
+	if client.lanes != nil {
+		lanesReady = client.lanes.ready
+	}
 
-	// Handle client events
 	for {
+		var err error
 		select {
-		case event := <-client.EventCh:
-			if err := s.sendEventToClient(client, event); err != nil {
-				s.removeClient(clientID)
+		case event, ok := <-client.EventCh:
+			if !ok {
+				// Closed by the server or by serveClient
+				s.sendCloseEvent(client, "")
 				return
 			}
+			if err = s.drainPriority(client); err == nil {
+				err = s.deliver(client, event)
+			}
+		case event := <-client.priority:
+			err = s.deliver(client, event)
+		case <-lanesReady:
+			err = s.drainLanes(client)
+		case <-flushTick:
+			err = s.flushClient(client)
 		case <-s.ctx.Done():
-			s.removeClient(clientID)
+			s.sendCloseEvent(client, CloseReasonShutdown)
+			s.disconnectClient(client, "")
 			return
-		case <-r.Context().Done():
-			s.removeClient(clientID)
+		}
+
+		if errors.Is(err, errClientClosed) {
+			s.sendCloseEvent(client, "")
+			return
+		}
+		if err != nil {
+			s.disconnectClient(client, "")
 			return
 		}
 	}
 }
 
-// Broadcast sends an event to all connected clients
-func (s *Server) Broadcast(event Event) {
+// acceptClient validates the request, then creates and registers its client
+// under authorizedID, when not empty, along with any history it asked to
+// replay. On failure it writes the HTTP error and returns a nil client.
+func (s *Server) acceptClient(w http.ResponseWriter, r *http.Request, authorizedID string) (*Client, []Event) {
+	// Check connection limit
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	if len(s.clients) >= s.config.MaxConnections {
+		s.mu.RUnlock()
+		s.rejected.Add(1)
+		http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+		return nil, nil
+	}
+	s.mu.RUnlock()
 
-	for _, client := range s.clients {
-		select {
-		case client.EventCh <- event:
-		default:
-			// Channel is full, remove client
-			go s.removeClient(client.ID)
+	token, resumed := s.lookupResume(r)
+
+	types, err := requestTypes(r, resumed, s.config.MaxSubscriptionHeaderBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestHeaderFieldsTooLarge)
+		return nil, nil
+	}
+	filter, err := requestFilter(r, resumed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil
+	}
+
+	// Negotiate data encoding
+	encoding, encoder, err := s.negotiateEncoder(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return nil, nil
+	}
+
+	// Resolve user identity
+	var identity string
+	if s.config.IdentityFunc != nil {
+		identity = s.config.IdentityFunc(r)
+	}
+
+	// Create client
+	clientID := s.clientIDFor(r, authorizedID)
+	conn := s.compressStream(w, r)
+	client := &Client{
+		ID:         clientID,
+		EventCh:    make(chan Event, s.config.BufferSize),
+		conn:       conn,
+		flusher:    http.NewResponseController(conn),
+		out:        s.newCoalescingWriter(conn),
+		lanes:      s.newTypeLanes(),
+		priority:   make(chan Event, priorityBufferSize),
+		server:     s,
+		Identity:   identity,
+		Version:    r.Header.Get(clientVersionHeader),
+		Attributes: s.requestAttributes(r),
+		connected:  time.Now(),
+		filter:     filter,
+		types:      types,
+		encoder:    encoder,
+		encoding:   encoding,
+		limiter:    s.newClientLimiter(),
+		preamble:   s.streamPreamble(),
+	}
+
+	// Register client and snapshot sticky events and history together so
+	// each reaches it exactly once, either by replay or on its channel
+	s.mu.Lock()
+	existing, duplicate := s.clients[clientID]
+	if duplicate && s.config.DuplicateIDPolicy != DuplicateReplaceOld {
+		s.mu.Unlock()
+		http.Error(w, "Client ID already connected", http.StatusConflict)
+		return nil, nil
+	}
+	s.clients[clientID] = client
+	s.subscribeLocked(client, existing)
+	replay := s.stickyEventsLocked()
+	var cursor string
+	if s.history != nil {
+		switch lastEventID := s.lastEventID(r, resumed); {
+		case r.URL.Query().Get("replay") == "all":
+			replay = append(replay, s.history.snapshot()...)
+		case lastEventID != "":
+			replay = append(replay, s.history.since(lastEventID)...)
 		}
+		replay = s.visibleLocked(client, replay)
+		cursor = s.history.lastID()
+		// Broadcasts made during replay come from history rather than the
+		// channel, so a long replay cannot overflow it
+		if len(replay) > 0 {
+			client.replaying = true
+			client.replayed = s.history.latest()
+		}
+	}
+	s.holdReplayLocked(client, replay)
+	s.mu.Unlock()
+	s.accepted.Add(1)
+	s.logger.Debugf("sse: client %s connected", clientID)
+
+	// Headers cannot change once streaming starts, so the cookie records the
+	// position at connect time; clients may update it from lastEventId
+	if s.config.CursorCookieName != "" && cursor != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     s.config.CursorCookieName,
+			Value:    cursor,
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	s.issueResumeToken(w, client, token, resumed)
+
+	// The replaced connection's own cleanup will not touch the new entry
+	if duplicate {
+		existing.close(CloseReasonReplaced)
+	}
+
+	if identity != "" {
+		s.reconnects.record(identity, time.Now())
 	}
+
+	return client, replay
 }
 
-// BroadcastToType sends an event only to clients subscribed to a specific event type
-func (s *Server) BroadcastToType(_ string, event Event) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// admitRequest checks the request's protocol and runs Config.Authorize,
+// writing the HTTP error when the connection may not proceed. It returns
+// the client ID chosen by Authorize, if any.
+func (s *Server) admitRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !s.admitProtocol(w, r) {
+		return "", false
+	}
 
-	// For now, broadcast to all clients since we don't have type-based subscription
-	// In a real implementation, you would track client subscriptions by type
-	for _, client := range s.clients {
-		select {
-		case client.EventCh <- event:
-		default:
-			// Channel is full, remove client
-			go s.removeClient(client.ID)
+	authorizedID, ok := s.authorize(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return authorizedID, true
+}
+
+// admitProtocol handles HTTP/1.0 requests, which cannot use chunked
+// encoding: the stream is then sent unframed and ends when the connection
+// closes, unless Config.RejectHTTP10 refuses it with 505
+func (s *Server) admitProtocol(w http.ResponseWriter, r *http.Request) bool {
+	if r.ProtoAtLeast(1, 1) {
+		return true
+	}
+
+	w.Header().Set("Connection", "close")
+	if s.config.RejectHTTP10 {
+		http.Error(w, "SSE requires HTTP/1.1 or later", http.StatusHTTPVersionNotSupported)
+		return false
+	}
+	return true
+}
+
+// authorize runs Config.Authorize, reporting whether the connection may
+// proceed and the client ID it should use, if any
+func (s *Server) authorize(r *http.Request) (string, bool) {
+	if s.config.Authorize == nil {
+		return "", true
+	}
+	return s.config.Authorize(r)
+}
+
+// clientIDFor returns the ID for a new client: the one Config.Authorize
+// chose, then Config.ClientIDFunc's, then a generated one
+func (s *Server) clientIDFor(r *http.Request, authorizedID string) string {
+	if authorizedID != "" {
+		return authorizedID
+	}
+	if s.config.ClientIDFunc != nil {
+		if clientID := s.config.ClientIDFunc(r); clientID != "" {
+			return clientID
 		}
 	}
+	return generateClientID()
 }
 
-// GetConnectionCount returns the current number of active connections
-func (s *Server) GetConnectionCount() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.clients)
+// lastEventID returns the client's resume position from the Last-Event-ID
+// header, falling back to Config.CursorCookieName and then to the position
+// saved under the client's resume token
+func (s *Server) lastEventID(r *http.Request, resumed *resumeState) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	if s.config.CursorCookieName != "" {
+		if cookie, err := r.Cookie(s.config.CursorCookieName); err == nil {
+			return cookie.Value
+		}
+	}
+	if resumed != nil {
+		return resumed.lastEventID
+	}
+	return ""
 }
 
-// Shutdown gracefully shuts down the server and closes all connections
-func (s *Server) Shutdown() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// deliver applies the client's filter and writes the event. Events that are
+// filtered out or cannot be encoded are skipped without error.
+func (s *Server) deliver(client *Client, event Event) error {
+	// Heartbeats bypass filters so idle filtered clients stay alive
+	if client.filter != nil && event.Type != "heartbeat" && !client.filter.match(event, event.normalizedData) {
+		event.tracker.skip(client.ID)
+		return nil
+	}
 
-	// Cancel context to stop heartbeat
-	s.cancel()
+	err := errClientClosed
+	if s.paceClient(client, event) {
+		err = s.sendEventToClient(client, event)
+	}
+	event.tracker.done(client.ID, err)
+	if errors.Is(err, ErrEventTooLarge) || errors.Is(err, ErrMarshalFailed) {
+		// Unencodable events are skipped, the connection stays usable
+		return nil
+	}
+	return err
+}
 
-	// Close all client connections
-	for _, client := range s.clients {
-		client.close()
+// Broadcast sends an event to all connected clients
+func (s *Server) Broadcast(event Event) {
+	if !s.throttleBroadcast() {
+		return
+	}
+	for _, e := range s.expandEvent(event) {
+		s.broadcastTo(e, nil, true)
 	}
+}
 
-	// Clear maps
-	s.clients = make(map[string]*Client)
-	s.clientsByType = make(map[string]map[string]*Client)
+// BroadcastCtx sends an event to all connected clients like Broadcast,
+// passing ctx to Config.EventMiddleware so it can read request-scoped values
+// such as trace IDs or the tenant. Only ctx's values are used; cancelling it
+// does not stop the broadcast.
+func (s *Server) BroadcastCtx(ctx context.Context, event Event) {
+	event.ctx = ctx
+	s.Broadcast(event)
+}
 
-	// Signal shutdown
-	close(s.shutdown)
+// broadcast fans an event out to every client without throttling
+func (s *Server) broadcast(event Event) {
+	s.broadcastTo(event, nil, false)
 }
 
-// sendEventToClient sends an event to a specific client
-func (s *Server) sendEventToClient(client *Client, event Event) error {
-	client.mu.Lock()
-	defer client.mu.Unlock()
+// broadcastTo fans an event out to clients accepted by match, or to every
+// client when match is nil. Published events are also retained in history
+// and forwarded to webhooks, after passing through Config.EventMiddleware.
+// Events failing schema validation are dropped. It returns the number of
+// clients matched.
+func (s *Server) broadcastTo(event Event, match func(*Client) bool, publish bool) int {
+	if event.route == "" && event.target == "" {
+		event.route = routeAll
+	}
+	if publish {
+		event = s.applyMiddleware(event)
+	}
+	if !s.validateBroadcast(event) {
+		return 0
+	}
+	// Clients encode the data later, so take it out of the caller's hands now
+	event.Data = snapshotData(event.Data)
 
-	if client.closed {
-		return fmt.Errorf("client connection closed")
+	if s.ordered != nil {
+		return s.submitOrdered(event, match, publish)
 	}
+	return s.deliverBroadcast(event, match, publish)
+}
 
-	// Format event according to SSE specification
-	var eventStr string
+// deliverBroadcast assigns a published event its ID, records and forwards
+// it, then queues it for the matching clients
+func (s *Server) deliverBroadcast(event Event, match func(*Client) bool, publish bool) int {
+	if publish {
+		event = s.assignEventID(event)
+		s.notifyWebhooks(event)
+		s.publishLocal(event, event.target)
+	}
 
-	if event.ID != "" {
-		eventStr += fmt.Sprintf("id: %s\n", event.ID)
+	if !s.enforceBufferCap() {
+		return 0
 	}
 
-	if event.Type != "" {
-		eventStr += fmt.Sprintf("event: %s\n", event.Type)
+	return s.fanOut(s.snapshotClients(publish, event), event, match)
+}
+
+// applyMiddleware runs Config.EventMiddleware with the event's BroadcastCtx
+// context, or the background context, and detaches that context so it is
+// not retained in history. Routing state is kept even if the middleware
+// returns a fresh Event.
+func (s *Server) applyMiddleware(event Event) Event {
+	ctx := event.ctx
+	event.ctx = nil
+	if s.config.EventMiddleware == nil {
+		return event
+	}
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	// Convert data to string
-	var dataStr string
-	switch v := event.Data.(type) {
-	case string:
-		dataStr = v
-	case []byte:
-		dataStr = string(v)
-	default:
-		// Try to marshal as JSON
-		if jsonData, err := json.Marshal(event.Data); err == nil {
-			dataStr = string(jsonData)
-		} else {
-			dataStr = fmt.Sprintf("%v", event.Data)
-		}
+	out := s.config.EventMiddleware(ctx, event)
+	out.tracker, out.target, out.retry, out.waiter = event.tracker, event.target, event.retry, event.waiter
+	out.sequenced = event.sequenced
+	out.ctx = nil
+	return out
+}
+
+// snapshotClients copies the current client set, or the subscribers of the
+// event's target type, so fan-out can happen without holding the server
+// lock. When record is set, event is added to history under the same lock
+// so registration sees a consistent cut.
+func (s *Server) snapshotClients(record bool, event Event) []*Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if record && s.history != nil {
+		s.history.add(event)
 	}
 
-	eventStr += fmt.Sprintf("data: %s\n\n", dataStr)
+	source := s.clients
+	if event.target != "" {
+		source = s.clientsByType[event.target]
+	}
 
-	// Write to connection
-	if _, err := client.conn.Write([]byte(eventStr)); err != nil {
-		return err
+	clients := make([]*Client, 0, len(source))
+	for _, client := range source {
+		if record && client.replaying {
+			continue
+		}
+		clients = append(clients, client)
 	}
+	return clients
+}
 
-	// Flush the response
-	if flusher, ok := client.conn.(http.Flusher); ok {
-		flusher.Flush()
+// BroadcastToType sends an event only to clients subscribed to eventType,
+// via the types query parameter of their connection. Clients without
+// subscriptions do not receive it.
+func (s *Server) BroadcastToType(eventType string, event Event) {
+	if !s.throttleBroadcast() {
+		return
 	}
 
-	return nil
+	for _, e := range s.expandEvent(event) {
+		e.target = eventType
+		s.broadcastTo(e, nil, true)
+	}
 }
 
-// removeClient removes a client from the server
-func (s *Server) removeClient(clientID string) {
+// RenameType moves every subscriber of oldType to newType in one step, so
+// clients follow a renamed event type without reconnecting. Subscribers
+// already under newType are kept.
+func (s *Server) RenameType(oldType, newType string) {
+	if oldType == newType {
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if client, exists := s.clients[clientID]; exists {
-		client.close()
-		delete(s.clients, clientID)
+	moved, ok := s.clientsByType[oldType]
+	if !ok {
+		return
+	}
+	delete(s.clientsByType, oldType)
 
-		// Remove from type-specific maps
-		for _, clients := range s.clientsByType {
-			delete(clients, clientID)
+	target := s.clientsByType[newType]
+	if target == nil {
+		target = make(map[string]*Client, len(moved))
+		s.clientsByType[newType] = target
+	}
+	for id, client := range moved {
+		if client.Type == oldType {
+			client.Type = newType
 		}
+		client.types = renamedTypes(client.types, oldType, newType)
+		target[id] = client
 	}
 }
 
-// heartbeat sends periodic heartbeat events to keep connections alive
-func (s *Server) heartbeat() {
-	ticker := time.NewTicker(s.config.HeartbeatInterval)
-	defer ticker.Stop()
+// BroadcastToIdentity sends an event to every connection of the given user
+// identity, as resolved by Config.IdentityFunc
+func (s *Server) BroadcastToIdentity(identity string, event Event) {
+	event.route = "identity:" + identity
+	s.BroadcastFunc(func(c *ClientInfo) bool {
+		return c.Identity == identity
+	}, event)
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.Broadcast(Event{
-				Type: "heartbeat",
-				Data: time.Now().Unix(),
-			})
-		case <-s.ctx.Done():
-			return
+// ClientsByIdentity returns the IDs of all connections for the given user identity
+func (s *Server) ClientsByIdentity(identity string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, client := range s.clients {
+		if client.Identity == identity {
+			ids = append(ids, id)
 		}
 	}
+	return ids
 }
 
-// close closes the client connection
-func (c *Client) close() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// expandEvent splits slice data into one event per element when
+// Config.SplitSliceData is set. Split events share the type, metadata and
+// routing of the original and get sequential IDs derived from its ID, if
+// it has one.
+func (s *Server) expandEvent(event Event) []Event {
+	if !s.config.SplitSliceData || event.Data == nil {
+		return []Event{event}
+	}
 
-	if !c.closed {
-		c.closed = true
-		close(c.EventCh)
+	v := reflect.ValueOf(event.Data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []Event{event}
+	}
+	if _, isBytes := event.Data.([]byte); isBytes {
+		return []Event{event}
+	}
+
+	events := make([]Event, v.Len())
+	for i := range events {
+		// Without an ID of its own each element is left for AutoEventID, so
+		// Last-Event-ID resumes stay unambiguous across broadcasts
+		var id string
+		if event.ID != "" {
+			id = event.ID + "-" + strconv.Itoa(i)
+		}
+		events[i] = Event{
+			Type:   event.Type,
+			Data:   v.Index(i).Interface(),
+			ID:     id,
+			Meta:   event.Meta,
+			target: event.target,
+			retry:  event.retry,
+			ctx:    event.ctx,
+			route:  event.route,
+		}
+	}
+	return events
+}
+
+// GetMarshalFailureCount returns how many event deliveries had data that
+// could not be marshaled as JSON
+func (s *Server) GetMarshalFailureCount() int64 {
+	return s.marshalFailures.Load()
+}
+
+// GetConnectionCount returns the current number of active connections
+func (s *Server) GetConnectionCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients)
+}
+
+// GetClientIDs returns a sorted snapshot of the IDs of active connections
+func (s *Server) GetClientIDs() []string {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.clients))
+	for id := range s.clients {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(ids)
+	return ids
+}
+
+// HasClient reports whether a client with the given ID is connected
+func (s *Server) HasClient(clientID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.clients[clientID]
+	return exists
+}
+
+// Shutdown gracefully shuts down the server and closes all connections. It
+// returns once the heartbeat has stopped and is safe to call more than once.
+func (s *Server) Shutdown() {
+	var clients []*Client
+
+	s.mu.Lock()
+	select {
+	case <-s.shutdown:
+		// Already shut down
+	default:
+		// Cancel context to stop heartbeat
+		s.cancel()
+		s.scheduled.stop()
+
+		s.logger.Infof("sse: shutting down, closing %d clients", len(s.clients))
+		clients = make([]*Client, 0, len(s.clients))
+		for _, client := range s.clients {
+			clients = append(clients, client)
+		}
+		for sub := range s.subscribers {
+			s.removeSubscriberLocked(sub)
+		}
+
+		// Clear maps
+		s.clients = make(map[string]*Client)
+		s.clientsByType = make(map[string]map[string]*Client)
+		s.rooms = make(map[string]map[string]*Client)
+
+		// Signal shutdown
+		close(s.shutdown)
+	}
+	s.mu.Unlock()
+
+	// Close outside the server lock since closing waits for any in-progress
+	// write, and a stalled client must not hold up every other server path
+	for _, client := range clients {
+		client.close(CloseReasonShutdown)
+	}
+
+	// Background loops take s.mu, so wait for them only once it is released
+	s.background.Wait()
+}
+
+// ShutdownContext shuts the server down like Shutdown, but gracefully, in
+// the manner of http.Server.Shutdown. New connections are refused, in-flight
+// history replays are allowed to finish so reconnecting clients are not left
+// half-replayed, and events already queued for clients are delivered before
+// the connections are closed, each with a final close event whether or not
+// Config.SendCloseEvent is set. If ctx is done first, whatever is left is cut off, the server is
+// shut down anyway and ctx.Err() is returned.
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	err := s.waitReplays(ctx)
+	if err == nil {
+		err = s.waitDrained(ctx)
+	}
+
+	s.Shutdown()
+	return err
+}
+
+// waitReplays waits for the replays counted in s.replays or for ctx
+func (s *Server) waitReplays(ctx context.Context) error {
+	replayed := make(chan struct{})
+	go func() {
+		s.replays.Wait()
+		close(replayed)
+	}()
+
+	select {
+	case <-replayed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainPollInterval is how often ShutdownContext checks for drained buffers
+const drainPollInterval = 10 * time.Millisecond
+
+// waitDrained waits until no events are queued for any client, or for ctx
+func (s *Server) waitDrained(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.pendingEvents() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// pendingEvents counts the events queued for clients, priority events included
+func (s *Server) pendingEvents() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := s.totalBufferedLocked()
+	for _, client := range s.clients {
+		total += len(client.priority)
+	}
+	return total
+}
+
+// Wait blocks until Shutdown has completed and every HandleSSE call has
+// returned
+func (s *Server) Wait() {
+	<-s.shutdown
+	s.handlers.Wait()
+}
+
+// trackHandler counts a HandleSSE call towards Wait. It fails once the server
+// is shutting down; holding the read lock orders the Add before Shutdown signals.
+func (s *Server) trackHandler() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopping {
+		return false
+	}
+
+	select {
+	case <-s.shutdown:
+		return false
+	default:
+		s.handlers.Add(1)
+		return true
+	}
+}
+
+// sendEventToClient sends an event to a specific client
+func (s *Server) sendEventToClient(client *Client, event Event) error {
+	if s.config.BeforeSend != nil {
+		event = s.config.BeforeSend(client.ID, event)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.closed {
+		return errClientClosed
+	}
+
+	if err := s.writeEvent(client, event); err != nil {
+		s.logger.Debugf("sse: write to client %s failed: %v", client.ID, err)
+		return err
+	}
+	s.eventsSent.Add(1)
+	if event.ID != "" {
+		client.lastEventID = event.ID
+	}
+	return nil
+}
+
+// writeEvent formats and writes an event to the client connection. Callers must hold client.mu.
+func (s *Server) writeEvent(client *Client, event Event) error {
+	if s.config.DataOnly {
+		event = dataOnlyEvent(event)
+	}
+
+	// Encode data before building the frame so oversized payloads are rejected early
+	dataStr, err := s.encodeForClient(client, event.Data)
+	if errors.Is(err, ErrMarshalFailed) {
+		s.marshalFailures.Add(1)
+		if s.config.MarshalFallback == MarshalFallbackSkip {
+			return err
+		}
+		dataStr = sanitizeLine(fmt.Sprintf("%v", event.Data))
+		if s.config.MaxEventBytes > 0 && len(dataStr) > s.config.MaxEventBytes {
+			return ErrEventTooLarge
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// Format event according to SSE specification, after the preamble on
+	// the first write
+	eventStr := client.preamble + formatMeta(event.Meta, event.leadingMeta()...) + s.routingComment(event)
+	client.preamble = ""
+
+	if event.retry > 0 {
+		eventStr += fmt.Sprintf("retry: %d\n", event.retry)
+	}
+
+	// ID and type are single fields, so line breaks would inject new ones
+	if event.ID != "" {
+		eventStr += fmt.Sprintf("id: %s\n", sanitizeLine(event.ID))
+	}
+
+	if event.Type != "" {
+		eventStr += fmt.Sprintf("event: %s\n", sanitizeLine(event.Type))
+	}
+
+	// Each line of multi-line data gets its own data field. CR and CRLF end
+	// lines in SSE too, so they are split on like LF.
+	for _, line := range splitLines(dataStr) {
+		eventStr += fmt.Sprintf("data: %s\n", line)
+	}
+	eventStr += "\n"
+
+	// Coalesced writes are flushed later by the client loop
+	if client.out != nil {
+		if _, err := client.out.WriteString(eventStr); err != nil {
+			return err
+		}
+		client.lastSend.Store(time.Now().UnixNano())
+		return nil
+	}
+
+	// Write to connection
+	if _, err := client.conn.Write([]byte(eventStr)); err != nil {
+		return err
+	}
+
+	// Flush the response; a failed flush means the client is gone
+	if err := s.flushConn(client); err != nil {
+		return err
+	}
+
+	client.lastSend.Store(time.Now().UnixNano())
+	return nil
+}
+
+// newCoalescingWriter returns a buffered writer over w when flush
+// coalescing is enabled, or nil otherwise
+func (s *Server) newCoalescingWriter(w http.ResponseWriter) *bufio.Writer {
+	if s.config.FlushInterval <= 0 {
+		return nil
+	}
+	return bufio.NewWriter(w)
+}
+
+// flushClient writes out any coalesced bytes and flushes the response. It
+// is safe to call after the client has been closed, so the close path can
+// push out the last events.
+func (s *Server) flushClient(client *Client) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.out != nil && client.out.Buffered() > 0 {
+		if err := client.out.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.flushConn(client)
+}
+
+// flushConn flushes the response, bounded by Config.FlushTimeout when the
+// connection supports write deadlines. Callers must hold client.mu.
+func (s *Server) flushConn(client *Client) error {
+	if s.config.FlushTimeout <= 0 {
+		return client.flusher.Flush()
+	}
+
+	// Writers without deadline support are flushed unbounded
+	if err := client.flusher.SetWriteDeadline(time.Now().Add(s.config.FlushTimeout)); err != nil {
+		return client.flusher.Flush()
+	}
+	if err := client.flusher.Flush(); err != nil {
+		return err
+	}
+	return client.flusher.SetWriteDeadline(time.Time{})
+}
+
+// dataOnlyEnvelope carries an event's type and ID inside its data for
+// Config.DataOnly
+type dataOnlyEnvelope struct {
+	Type string      `json:"type,omitempty"`
+	ID   string      `json:"id,omitempty"`
+	Data interface{} `json:"data"`
+}
+
+// dataOnlyEvent moves the type and ID of event into a JSON envelope so it
+// is written as a single data field. Byte data is embedded as text, as it
+// would otherwise be written. Everything else, such as the meta, retry and
+// routing, is kept.
+func dataOnlyEvent(event Event) Event {
+	data := event.Data
+	if b, ok := data.([]byte); ok {
+		data = string(b)
+	}
+	event.Data = dataOnlyEnvelope{Type: event.Type, ID: event.ID, Data: data}
+	event.Type, event.ID = "", ""
+	return event
+}
+
+// encodeForClient encodes data with the client's negotiated encoder, falling
+// back to encodeData when none was negotiated
+func (s *Server) encodeForClient(client *Client, data interface{}) (string, error) {
+	if client.encoder == nil {
+		// Data-only frames must stay on a single line
+		return encodeData(data, s.config.MaxEventBytes, s.config.PrettyJSON && !s.config.DataOnly)
+	}
+
+	dataStr, err := client.encoder.Encode(data)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMarshalFailed, err)
+	}
+	if s.config.MaxEventBytes > 0 && len(dataStr) > s.config.MaxEventBytes {
+		return "", ErrEventTooLarge
+	}
+	return dataStr, nil
+}
+
+// encodeData converts event data to its wire representation. When limit is
+// positive, JSON output is streamed through a bounded writer and encoding
+// stops with ErrEventTooLarge as soon as the limit is crossed, before the
+// rest of the value is marshalled. With indent set, JSON is pretty-printed
+// over multiple lines.
+func encodeData(data interface{}, limit int, indent bool) (string, error) {
+	var dataStr string
+	switch v := data.(type) {
+	case string:
+		dataStr = v
+	case []byte:
+		dataStr = string(v)
+	default:
+		encoded, err := marshalBounded(data, limit)
+		if errors.Is(err, ErrEventTooLarge) {
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrMarshalFailed, err)
+		}
+		if !indent {
+			return string(encoded), nil
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, encoded, "", "  "); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrMarshalFailed, err)
+		}
+		dataStr = out.String()
+	}
+
+	if limit > 0 && len(dataStr) > limit {
+		return "", ErrEventTooLarge
+	}
+	return dataStr, nil
+}
+
+// marshalBounded encodes data as compact JSON, walking it with
+// encodeBounded when limit is positive. Without a limit there is nothing
+// to stop early for, so data is marshalled directly.
+func marshalBounded(data interface{}, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return json.Marshal(data)
+	}
+	lw := &limitedWriter{limit: limit}
+	if err := encodeBounded(lw, reflect.ValueOf(data)); err != nil {
+		return nil, err
+	}
+	return lw.buf.Bytes(), nil
+}
+
+// encodeBounded writes v as compact JSON to w. Slices, arrays, structs and
+// string-keyed maps are walked element by element so an oversized value
+// fails after roughly limit bytes instead of being marshalled in full;
+// everything else is marshalled as a single leaf.
+func encodeBounded(w *limitedWriter, v reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() || implementsMarshaler(v) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || implementsMarshaler(v) {
+		return encodeLeaf(w, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return encodeLeaf(w, v)
+		}
+		return encodeElements(w, v)
+	case reflect.Array:
+		return encodeElements(w, v)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return encodeLeaf(w, v)
+		}
+		return encodeMap(w, v)
+	case reflect.Struct:
+		fields, ok := jsonFieldsOf(v.Type())
+		if !ok {
+			return encodeLeaf(w, v)
+		}
+		return encodeStruct(w, v, fields)
+	default:
+		return encodeLeaf(w, v)
 	}
 }
 
-// generateClientID generates a unique client ID
+// encodeElements writes the elements of a slice or array as a JSON array
+func encodeElements(w *limitedWriter, v reflect.Value) error {
+	if _, err := w.Write([]byte{'['}); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			if _, err := w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		if err := encodeBounded(w, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{']'})
+	return err
+}
+
+// encodeMap writes a string-keyed map as a JSON object with sorted keys,
+// matching encoding/json
+func encodeMap(w *limitedWriter, v reflect.Value) error {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	if _, err := w.Write([]byte{'{'}); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if i > 0 {
+			if _, err := w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		if err := encodeLeaf(w, reflect.ValueOf(key.String())); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{':'}); err != nil {
+			return err
+		}
+		if err := encodeBounded(w, v.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{'}'})
+	return err
+}
+
+// encodeStruct writes the fields of a struct as a JSON object, in the order
+// and under the names encoding/json would use
+func encodeStruct(w *limitedWriter, v reflect.Value, fields []jsonField) error {
+	if _, err := w.Write([]byte{'{'}); err != nil {
+		return err
+	}
+	wrote := false
+	for _, field := range fields {
+		fv, err := v.FieldByIndexErr(field.index)
+		if err != nil {
+			// Promoted through a nil embedded pointer
+			continue
+		}
+		if field.omitEmpty && isEmptyJSONValue(fv) {
+			continue
+		}
+		if wrote {
+			if _, err := w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		wrote = true
+		if err := encodeLeaf(w, reflect.ValueOf(field.name)); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{':'}); err != nil {
+			return err
+		}
+		if err := encodeBounded(w, fv); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{'}'})
+	return err
+}
+
+// encodeLeaf marshals v in one piece and writes it to w. Addressable values
+// are marshalled through a pointer so pointer-receiver marshalers apply, as
+// they would in encoding/json.
+func encodeLeaf(w *limitedWriter, v reflect.Value) error {
+	var data interface{}
+	if v.CanAddr() {
+		data = v.Addr().Interface()
+	} else if v.IsValid() {
+		data = v.Interface()
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// implementsMarshaler reports whether v controls its own JSON encoding and
+// must therefore be marshalled as a leaf
+func implementsMarshaler(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+	if !v.CanAddr() {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// formatMeta renders event metadata as comment lines, which standard
+// EventSource clients ignore. The leading keys present in meta come first
+// in the order given, then the rest sorted by key.
+func formatMeta(meta map[string]string, leading ...string) string {
+	if len(meta) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		if !slices.Contains(leading, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	first := make([]string, 0, len(leading))
+	for _, key := range leading {
+		if _, ok := meta[key]; ok {
+			first = append(first, key)
+		}
+	}
+	keys = append(first, keys...)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, ": %s=%s\n", sanitizeLine(key), sanitizeLine(meta[key]))
+	}
+	return b.String()
+}
+
+// lineBreaks normalizes the line endings SSE recognizes to LF
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// splitLines splits s on CRLF, CR and LF
+func splitLines(s string) []string {
+	return strings.Split(lineBreaks.Replace(s), "\n")
+}
+
+// sanitizeLine replaces control characters so s cannot break SSE framing
+func sanitizeLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// limitedWriter buffers writes up to limit bytes and fails once it would be exceeded
+type limitedWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+// Write appends p to the buffer unless doing so would exceed the limit
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.limit > 0 && w.buf.Len()+len(p) > w.limit {
+		return 0, ErrEventTooLarge
+	}
+	return w.buf.Write(p)
+}
+
+// CloseClient disconnects a client from the server side. Any coalesced
+// output is flushed and, with Config.SendCloseEvent, a close event with
+// reason "closed" is sent first. It reports whether the client was found.
+func (s *Server) CloseClient(clientID string) bool {
+	s.mu.RLock()
+	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
+
+	if exists {
+		s.disconnectClient(client, CloseReasonClosed)
+	}
+	return exists
+}
+
+// removeClient removes a client from the server
+func (s *Server) removeClient(clientID string) {
+	s.mu.RLock()
+	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
+
+	if exists {
+		s.disconnectClient(client, "")
+	}
+}
+
+// disconnectClient removes a client, recording reason for the close event
+// when the server initiated the disconnect. The registry entry is only
+// deleted if it still refers to this client, so a stale connection never
+// removes a newer one registered under the same ID.
+func (s *Server) disconnectClient(client *Client, reason string) {
+	s.mu.Lock()
+	current, exists := s.clients[client.ID]
+	removed := exists && current == client
+	if removed {
+		delete(s.clients, client.ID)
+
+		s.unsubscribeAllLocked(client)
+		s.leaveAllRoomsLocked(client)
+	}
+	s.mu.Unlock()
+
+	if removed {
+		s.logRemoval(client.ID, reason)
+	}
+
+	// Close outside the server lock since it waits for any in-progress write
+	client.close(reason)
+}
+
+// logRemoval logs why a client was removed. Slow consumers are warned
+// about, since they point at undersized buffers or stalled clients.
+func (s *Server) logRemoval(clientID, reason string) {
+	switch reason {
+	case "":
+		s.logger.Infof("sse: client %s disconnected", clientID)
+	case CloseReasonSlowConsumer, CloseReasonBufferLimit:
+		s.logger.Warnf("sse: client %s removed: %s", clientID, reason)
+	default:
+		s.logger.Infof("sse: client %s removed: %s", clientID, reason)
+	}
+}
+
+// notifyDisconnect calls Config.OnDisconnect once the client's stream has
+// ended. A connection replaced under the same ID is skipped, as that ID is
+// still connected.
+func (s *Server) notifyDisconnect(client *Client) {
+	if s.config.OnDisconnect == nil {
+		return
+	}
+
+	client.mu.Lock()
+	replaced := client.reason == CloseReasonReplaced
+	client.mu.Unlock()
+
+	if !replaced {
+		s.config.OnDisconnect(client.ID)
+	}
+}
+
+// heartbeat sends periodic heartbeat events to keep connections alive, and
+// sweeps reconnect history that has left its window
+func (s *Server) heartbeat() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(s.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Heartbeats bypass the broadcast throttle
+			var match func(*Client) bool
+			if s.config.CoalesceHeartbeat {
+				match = s.needsHeartbeat
+			}
+			s.broadcastTo(Event{
+				Type: "heartbeat",
+				Data: time.Now().Unix(),
+			}, match, false)
+			s.reconnects.sweep(time.Now())
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// isStopping reports whether ShutdownContext has started
+func (s *Server) isStopping() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stopping
+}
+
+// sendCloseEvent writes the terminal close event if Config.SendCloseEvent is
+// set or ShutdownContext is running, and the server initiated the
+// disconnect. The client's recorded reason
+// takes precedence over fallback. It must only be called from the goroutine
+// that owns the connection: HandleSSE, then writeLoop once it has started.
+func (s *Server) sendCloseEvent(client *Client, fallback string) {
+	if !s.config.SendCloseEvent && !s.isStopping() {
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	reason := client.reason
+	if reason == "" {
+		reason = fallback
+	}
+	if reason == "" || client.closeSent {
+		return
+	}
+	client.closeSent = true
+
+	_ = s.writeEvent(client, Event{
+		Type: "close",
+		Data: map[string]interface{}{"reason": reason},
+	})
+}
+
+// enqueue queues an event without blocking. It reports false only when the
+// channel is full; events for a closed client are silently dropped. The
+// closed check and the send share sendMu with close, so a client whose
+// handler has already exited can never be sent to after its channel closes.
+func (c *Client) enqueue(event Event) bool {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.closed {
+		return true
+	}
+
+	select {
+	case c.queueFor(event) <- event:
+		c.lanes.signal()
+		return true
+	default:
+		return false
+	}
+}
+
+// needsHeartbeat reports whether a client has been idle for a full heartbeat
+// interval with nothing queued, so real events are not doubled by heartbeats
+func (s *Server) needsHeartbeat(c *Client) bool {
+	if c.queued() > 0 {
+		return false
+	}
+	idle := time.Since(time.Unix(0, c.lastSend.Load()))
+	return idle >= s.config.HeartbeatInterval
+}
+
+// close closes the client connection, recording why the server closed it
+func (c *Client) close(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Wake senders waiting for room first, since they hold sendMu
+	c.startClosing()
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		c.reason = reason
+		close(c.EventCh)
+	}
+}
+
+// startClosing closes the closedSignal channel, once
+func (c *Client) startClosing() {
+	c.closingMu.Lock()
+	defer c.closingMu.Unlock()
+
+	if !c.closeStarted {
+		c.closeStarted = true
+		if c.closing != nil {
+			close(c.closing)
+		}
+	}
+}
+
+// closedSignal returns a channel that is closed as soon as the client starts
+// closing, so a goroutine waiting on the client can give up at once
+func (c *Client) closedSignal() <-chan struct{} {
+	c.closingMu.Lock()
+	defer c.closingMu.Unlock()
+
+	if c.closing == nil {
+		c.closing = make(chan struct{})
+		if c.closeStarted {
+			close(c.closing)
+		}
+	}
+	return c.closing
+}
+
+// generateClientID generates a unique client ID. IDs are random rather than
+// timestamp based so concurrent connections can never collide.
 func generateClientID() string {
-	return fmt.Sprintf("client_%d", time.Now().UnixNano())
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails when the OS entropy source is unusable
+		panic("sse: generating client ID: " + err.Error())
+	}
+	return "client_" + hex.EncodeToString(b)
 }
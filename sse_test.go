@@ -1,11 +1,22 @@
 package sse
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -39,6 +50,37 @@ func TestNewServerWithConfig(t *testing.T) {
 	}
 }
 
+func TestConfigReturnsEffectiveCopy(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 1_000_000
+	config.MaxConnections = 1000
+	config.MemoryBudget = 64 << 20
+	config.AllowedOrigins = []string{"https://a.example"}
+	config.Encoders = map[string]Encoder{"json": nil}
+
+	log.SetOutput(io.Discard)
+	server := NewServerWithConfig(config)
+	log.SetOutput(os.Stderr)
+	defer server.Shutdown()
+
+	effective := server.Config()
+	if effective.BufferSize >= config.BufferSize {
+		t.Errorf("Expected the clamped BufferSize, got the raw %d", effective.BufferSize)
+	}
+
+	server.SetAllowedOrigins([]string{"https://c.example", "https://b.example"})
+	origins := server.Config().AllowedOrigins
+	if len(origins) != 2 || origins[0] != "https://b.example" || origins[1] != "https://c.example" {
+		t.Errorf("Expected the origins set at runtime, got %v", origins)
+	}
+
+	effective.Encoders["gob"] = nil
+	effective.BufferSize = 1
+	if _, leaked := server.Config().Encoders["gob"]; leaked || server.Config().BufferSize == 1 {
+		t.Error("Expected modifying the returned config not to affect the server")
+	}
+}
+
 func TestHandleSSE(t *testing.T) {
 	server := NewServer()
 
@@ -112,36 +154,139 @@ func TestBroadcast(t *testing.T) {
 func TestBroadcastToType(t *testing.T) {
 	server := NewServer()
 
-	// Create test request
-	req := httptest.NewRequest("GET", "/events", http.NoBody)
-	w := httptest.NewRecorder()
+	targets := map[string]string{
+		"notifications": "/events?types=notification",
+		"multiple":      "/events?types=chat,%20notification",
+		"chat only":     "/events?types=chat",
+		"unsubscribed":  "/events",
+	}
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for name, target := range targets {
+		recorders[name] = httptest.NewRecorder()
+		go server.HandleSSE(recorders[name], httptest.NewRequest("GET", target, http.NoBody))
+	}
 
-	// Start SSE handler in goroutine
+	// Wait for connections to establish
+	time.Sleep(100 * time.Millisecond)
+
+	server.BroadcastToType("notification", Event{Type: "notification", Data: "Test notification"})
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "Test chat"})
+
+	// Wait for events to be processed
+	time.Sleep(100 * time.Millisecond)
+
+	// Shutdown server before reading response bodies to avoid race
+	server.Shutdown()
+
+	tests := []struct {
+		name         string
+		notification bool
+		chat         bool
+	}{
+		{"notifications", true, false},
+		{"multiple", true, true},
+		{"chat only", false, true},
+		{"unsubscribed", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := recorders[tt.name].Body.String()
+			if got := strings.Contains(body, "event: notification"); got != tt.notification {
+				t.Errorf("Expected notification delivered=%v, got %v", tt.notification, got)
+			}
+			if got := strings.Contains(body, "event: chat"); got != tt.chat {
+				t.Errorf("Expected chat delivered=%v, got %v", tt.chat, got)
+			}
+		})
+	}
+}
+
+func TestTypeBucketsCleanedUp(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events?types=chat,notification,chat", http.NoBody).WithContext(ctx)
+	done := make(chan struct{})
 	go func() {
-		server.HandleSSE(w, req)
+		server.HandleSSE(httptest.NewRecorder(), req)
+		close(done)
 	}()
+	time.Sleep(50 * time.Millisecond)
 
-	// Wait for connection to establish
-	time.Sleep(100 * time.Millisecond)
+	server.mu.RLock()
+	chat, notification := len(server.clientsByType["chat"]), len(server.clientsByType["notification"])
+	server.mu.RUnlock()
+	if chat != 1 || notification != 1 {
+		t.Errorf("Expected the client once under each type, got chat=%d notification=%d", chat, notification)
+	}
 
-	// Broadcast to specific type
-	event := Event{
-		Type: "notification",
-		Data: "Test notification",
+	cancel()
+	<-done
+
+	server.mu.RLock()
+	buckets := len(server.clientsByType)
+	server.mu.RUnlock()
+	if buckets != 0 {
+		t.Errorf("Expected all type buckets removed on disconnect, got %d", buckets)
 	}
+}
 
-	server.BroadcastToType("notification", event)
+func TestMaxSubscriptionHeaderBytes(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxSubscriptionHeaderBytes = 32
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
 
-	// Wait for event to be processed
-	time.Sleep(100 * time.Millisecond)
+	w := httptest.NewRecorder()
+	oversized := "/events?types=" + strings.Repeat("chat,", 10)
+	server.HandleSSE(w, httptest.NewRequest("GET", oversized, http.NoBody))
 
-	// Shutdown server before reading response body to avoid race
+	if w.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected status 431 for an oversized subscription, got %d", w.Code)
+	}
+	if server.GetConnectionCount() != 0 {
+		t.Error("Expected the oversized subscription not to be registered")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events?types=chat,news", http.NoBody).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if server.GetConnectionCount() != 1 {
+		t.Error("Expected a subscription within the limit to be accepted")
+	}
+	cancel()
+	<-done
+}
+
+func TestBroadcastToTypeReplay(t *testing.T) {
+	config := DefaultConfig()
+	config.HistorySize = 10
+	server := NewServerWithConfig(config)
+
+	server.BroadcastToType("chat", Event{ID: "1", Type: "chat", Data: "missed chat"})
+	server.BroadcastToType("alert", Event{ID: "2", Type: "alert", Data: "missed alert"})
+	server.Broadcast(Event{ID: "3", Type: "news", Data: "missed news"})
+
+	req := httptest.NewRequest("GET", "/events?types=chat&replay=all", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+	time.Sleep(100 * time.Millisecond)
 	server.Shutdown()
 
-	// Check response body contains the event
 	body := w.Body.String()
-	if !strings.Contains(body, "event: notification") {
-		t.Error("Response body does not contain notification event type")
+	if !strings.Contains(body, "missed chat") || !strings.Contains(body, "missed news") {
+		t.Errorf("Expected subscribed and untyped history to be replayed, got %q", body)
+	}
+	if strings.Contains(body, "missed alert") {
+		t.Error("Expected history for other types not to be replayed")
 	}
 }
 
@@ -175,6 +320,37 @@ func TestGetConnectionCount(t *testing.T) {
 	server.Shutdown()
 }
 
+func TestGetClientIDs(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if ids := server.GetClientIDs(); len(ids) != 0 {
+		t.Errorf("Expected no client IDs initially, got %v", ids)
+	}
+
+	addBareClient(server, "b", 1)
+	addBareClient(server, "a", 1)
+
+	ids := server.GetClientIDs()
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("Expected [a b], got %v", ids)
+	}
+
+	// The result is a copy
+	ids[0] = "changed"
+	if !server.HasClient("a") || server.HasClient("changed") {
+		t.Error("Expected mutating the result not to affect the server")
+	}
+
+	server.removeClient("a")
+	if server.HasClient("a") {
+		t.Error("Expected HasClient to be false after removal")
+	}
+	if ids := server.GetClientIDs(); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("Expected [b] after removal, got %v", ids)
+	}
+}
+
 func TestShutdown(t *testing.T) {
 	server := NewServer()
 
@@ -206,6 +382,113 @@ func TestShutdown(t *testing.T) {
 	}
 }
 
+func TestShutdownStopsHeartbeat(t *testing.T) {
+	config := DefaultConfig()
+	config.HeartbeatInterval = 10 * time.Millisecond
+	config.OccupancySampleInterval = 10 * time.Millisecond
+	server := NewServerWithConfig(config)
+
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.background.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Heartbeat goroutine still running after Shutdown")
+	}
+}
+
+func TestShutdownIdempotent(t *testing.T) {
+	server := NewServer()
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+	time.Sleep(100 * time.Millisecond)
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Second Shutdown panicked: %v", r)
+		}
+	}()
+
+	server.Shutdown()
+	server.Shutdown()
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections after shutdown, got %d", count)
+	}
+}
+
+func TestShutdownStalledClientDoesNotHoldServerLock(t *testing.T) {
+	server := NewServer()
+	stalled := addBareClient(server, "stalled", 1)
+	addBareClient(server, "other", 1)
+
+	// Hold the client lock as a write blocked on the network would
+	stalled.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		server.Shutdown()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	counted := make(chan int)
+	go func() { counted <- server.GetConnectionCount() }()
+	select {
+	case count := <-counted:
+		if count != 0 {
+			t.Errorf("Expected 0 connections after shutdown, got %d", count)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected the server lock to be free while a client write is stalled")
+	}
+
+	stalled.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Shutdown to finish once the write returned")
+	}
+}
+
+func TestShutdownConcurrentCalls(t *testing.T) {
+	server := NewServer()
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+	time.Sleep(50 * time.Millisecond)
+
+	// A signal handler and a deferred call racing each other
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				server.Shutdown()
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = server.ShutdownContext(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections after shutdown, got %d", count)
+	}
+}
+
 func TestConcurrentConnections(t *testing.T) {
 	server := NewServer()
 	var wg sync.WaitGroup
@@ -367,10 +650,94 @@ func TestContextCancellation(t *testing.T) {
 	server.Shutdown()
 }
 
-func BenchmarkBroadcast(b *testing.B) {
-	server := NewServer()
+func TestBoundedEncoderAbortsEarly(t *testing.T) {
+	type row struct {
+		ID      int    `json:"id"`
+		Payload string `json:"payload"`
+	}
+
+	rows := make([]row, 100000)
+	for i := range rows {
+		rows[i] = row{ID: i, Payload: "some reasonably long payload string"}
+	}
+
+	// A full marshal of rows allocates several megabytes; the bounded
+	// encoder must give up after producing roughly the limit
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	lw := &limitedWriter{limit: 1024}
+	err := encodeBounded(lw, reflect.ValueOf(rows))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("Expected ErrEventTooLarge, got %v", err)
+	}
+
+	if lw.buf.Len() > 1024 {
+		t.Errorf("Expected bounded writer to hold at most 1024 bytes, got %d", lw.buf.Len())
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64*1024 {
+		t.Errorf("Expected encoder to stop near the limit, allocated %d bytes", allocated)
+	}
+
+	if _, err := encodeData(rows, 1024, false); !errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("Expected encodeData to return ErrEventTooLarge, got %v", err)
+	}
+
+	if _, err := encodeData(rows[:1], 1024, false); err != nil {
+		t.Errorf("Expected small data to encode, got %v", err)
+	}
+
+	small := map[string]interface{}{"b": []int{1, 2}, "a": rows[:1], "c": nil}
+	got, err := encodeData(small, 1024, false)
+	want, _ := json.Marshal(small)
+	if err != nil || got != string(want) {
+		t.Errorf("Expected %s, got %q (%v)", want, got, err)
+	}
+}
+
+func TestBoundedEncoderAbortsEarlyInStruct(t *testing.T) {
+	type row struct {
+		ID      int    `json:"id"`
+		Payload string `json:"payload"`
+	}
+	type result struct {
+		Query string `json:"query"`
+		Rows  []row  `json:"rows"`
+		Total int    `json:"total"`
+	}
+
+	rows := make([]row, 100000)
+	for i := range rows {
+		rows[i] = row{ID: i, Payload: "some reasonably long payload string"}
+	}
+	data := result{Query: "select *", Rows: rows, Total: len(rows)}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	_, err := encodeData(data, 1024, false)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("Expected ErrEventTooLarge, got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64*1024 {
+		t.Errorf("Expected encoder to stop near the limit, allocated %d bytes", allocated)
+	}
+
+	data.Rows = rows[:2]
+	got, err := encodeData(&data, 1024, false)
+	want, _ := json.Marshal(&data)
+	if err != nil || got != string(want) {
+		t.Errorf("Expected %s, got %q (%v)", want, got, err)
+	}
+}
+
+func TestOversizedEventIsSkipped(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxEventBytes = 256
+	server := NewServerWithConfig(config)
 
-	// Create test connection
 	req := httptest.NewRequest("GET", "/events", http.NoBody)
 	w := httptest.NewRecorder()
 
@@ -380,34 +747,1718 @@ func BenchmarkBroadcast(b *testing.B) {
 
 	time.Sleep(100 * time.Millisecond)
 
-	event := Event{
-		Type: "benchmark",
-		Data: "test data",
-	}
+	server.Broadcast(Event{Type: "big", Data: strings.Repeat("x", 1000)})
+	server.Broadcast(Event{Type: "small", Data: "ok"})
 
-	b.ResetTimer()
+	time.Sleep(100 * time.Millisecond)
 
-	for i := 0; i < b.N; i++ {
-		server.Broadcast(event)
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected client to stay connected, got %d connections", count)
 	}
 
 	server.Shutdown()
+
+	body := w.Body.String()
+	if strings.Contains(body, "event: big") {
+		t.Error("Oversized event should not be written")
+	}
+
+	if !strings.Contains(body, "event: small") {
+		t.Error("Event after oversized one was not delivered")
+	}
 }
 
-func BenchmarkMultipleConnections(b *testing.B) {
-	server := NewServer()
+// assertWellFramed fails if any non-empty line in body is not a valid SSE field
+func assertWellFramed(t *testing.T, body string) {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if line == "" {
+			continue
+		}
+		valid := false
+		for _, prefix := range []string{"id: ", "event: ", "data: ", "retry: ", ":"} {
+			if strings.HasPrefix(line, prefix) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			t.Errorf("Stream framing corrupted by line %q", line)
+		}
+	}
+}
 
-	b.ResetTimer()
+func TestMarshalFailureFallback(t *testing.T) {
+	type unmarshalable struct {
+		Name string
+		Ch   chan int
+		Note string
+	}
 
-	for i := 0; i < b.N; i++ {
-		req := httptest.NewRequest("GET", "/events", http.NoBody)
-		w := httptest.NewRecorder()
+	tests := []struct {
+		name     string
+		fallback MarshalFallback
+		wantData bool
+	}{
+		{"sanitize", MarshalFallbackSanitize, true},
+		{"skip", MarshalFallbackSkip, false},
+	}
 
-		go func() {
-			server.HandleSSE(w, req)
-		}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.MarshalFallback = tt.fallback
+			server := NewServerWithConfig(config)
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			w := httptest.NewRecorder()
+
+			go func() {
+				server.HandleSSE(w, req)
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+
+			server.Broadcast(Event{
+				Type: "bad",
+				Data: unmarshalable{Name: "line1\nline2", Ch: make(chan int), Note: "\r\nevent: injected"},
+			})
+			server.Broadcast(Event{Type: "good", Data: "still flowing"})
+
+			time.Sleep(100 * time.Millisecond)
+
+			if count := server.GetMarshalFailureCount(); count != 1 {
+				t.Errorf("Expected 1 marshal failure, got %d", count)
+			}
+
+			server.Shutdown()
+
+			body := w.Body.String()
+			assertWellFramed(t, body)
+
+			if got := strings.Contains(body, "event: bad"); got != tt.wantData {
+				t.Errorf("Expected bad event present=%v, got %v", tt.wantData, got)
+			}
+
+			if !strings.Contains(body, "data: still flowing") {
+				t.Error("Event after marshal failure was not delivered")
+			}
+		})
 	}
+}
+
+func TestSplitSliceData(t *testing.T) {
+	config := DefaultConfig()
+	config.SplitSliceData = true
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.Broadcast(Event{
+		Type: "item",
+		Data: []string{"a", "b", "c"},
+		ID:   "batch",
+	})
+	server.Broadcast(Event{Type: "raw", Data: []byte("not split")})
 
 	time.Sleep(100 * time.Millisecond)
 	server.Shutdown()
+
+	body := w.Body.String()
+	if count := strings.Count(body, "event: item"); count != 3 {
+		t.Errorf("Expected 3 separate item events, got %d", count)
+	}
+
+	for i, data := range []string{"a", "b", "c"} {
+		frame := fmt.Sprintf("id: batch-%d\nevent: item\ndata: %s\n\n", i, data)
+		if !strings.Contains(body, frame) {
+			t.Errorf("Expected frame %q in response", frame)
+		}
+	}
+
+	if !strings.Contains(body, "data: not split") {
+		t.Error("Byte slice data should not be split")
+	}
+}
+
+func TestSplitSliceDataWithoutID(t *testing.T) {
+	config := DefaultConfig()
+	config.SplitSliceData = true
+	config.AutoEventID = true
+	server := NewServerWithConfig(config)
+	client := addBareClient(server, "c1", 10)
+
+	meta := map[string]string{"source": "batch"}
+	server.Broadcast(Event{Type: "item", Data: []string{"a", "b"}, Meta: meta})
+	server.Broadcast(Event{Type: "item", Data: []string{"c", "d"}, Meta: meta})
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		event := <-client.EventCh
+		ids = append(ids, event.ID)
+		if event.Meta["source"] != "batch" {
+			t.Errorf("Expected meta on element %d, got %v", i, event.Meta)
+		}
+	}
+
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected sequence IDs %v, got %v", want, ids)
+	}
+}
+
+// addBareClient registers a client without a running HandleSSE loop
+func addBareClient(server *Server, id string, bufferSize int) *Client {
+	client := &Client{
+		ID:      id,
+		EventCh: make(chan Event, bufferSize),
+		server:  server,
+	}
+
+	server.mu.Lock()
+	server.clients[id] = client
+	server.mu.Unlock()
+
+	return client
+}
+
+func TestBroadcastDuringRemoval(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	const clients = 200
+	for i := 0; i < clients; i++ {
+		addBareClient(server, fmt.Sprintf("client-%d", i), 1024)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			server.Broadcast(Event{Type: "tick", Data: i})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < clients; i++ {
+			server.removeClient(fmt.Sprintf("client-%d", i))
+		}
+	}()
+
+	wg.Wait()
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected all clients removed, got %d", count)
+	}
+}
+
+func TestConcurrentSendsAndDisconnects(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	const clients = 50
+	id := func(i int) string { return fmt.Sprintf("client-%d", i%clients) }
+
+	// Tiny unread buffers so broadcasts also remove slow consumers themselves
+	stop := make(chan struct{})
+	var churn sync.WaitGroup
+	churn.Add(2)
+	go func() {
+		defer churn.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			server.mu.RLock()
+			_, exists := server.clients[id(i)]
+			server.mu.RUnlock()
+			if !exists {
+				addBareClient(server, id(i), 1)
+			}
+		}
+	}()
+	go func() {
+		defer churn.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				server.removeClient(id(i))
+			} else {
+				server.CloseClient(id(i))
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	senders := []func(i int){
+		func(i int) { server.Broadcast(Event{Type: "tick", Data: i}) },
+		func(i int) { server.BroadcastToType("tick", Event{Type: "tick", Data: i}) },
+		func(i int) { server.BroadcastDeadline(Event{Type: "tick", Data: i}, time.Millisecond) },
+		func(i int) { _ = server.SendToClient(id(i), Event{Type: "tick", Data: i}) },
+		func(i int) { _ = server.SendPriority(id(i), Event{Type: "tick", Data: i}) },
+	}
+	for _, send := range senders {
+		wg.Add(1)
+		go func(send func(int)) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				send(i)
+			}
+		}(send)
+	}
+	wg.Wait()
+
+	close(stop)
+	churn.Wait()
+}
+
+func TestHandleSSEWaitsForWriter(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newStallWriter()
+	req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Block the writer mid-write, then cancel the request under it
+	w.stall()
+	server.Broadcast(Event{Type: "tick", Data: "stalled"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatal("Expected HandleSSE to wait for the in-flight write")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	w.unstall()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected HandleSSE to return once the writer stopped")
+	}
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections, got %d", count)
+	}
+}
+
+func TestBroadcastToIdentity(t *testing.T) {
+	config := DefaultConfig()
+	config.IdentityFunc = func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}
+	server := NewServerWithConfig(config)
+
+	newRequest := func(user string) *http.Request {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		req.Header.Set("X-User", user)
+		return req
+	}
+
+	tab1, tab2, other := httptest.NewRecorder(), httptest.NewRecorder(), httptest.NewRecorder()
+	go server.HandleSSE(tab1, newRequest("alice"))
+	go server.HandleSSE(tab2, newRequest("alice"))
+	go server.HandleSSE(other, newRequest("bob"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if ids := server.ClientsByIdentity("alice"); len(ids) != 2 {
+		t.Errorf("Expected 2 connections for alice, got %d", len(ids))
+	}
+
+	server.BroadcastToIdentity("alice", Event{Type: "direct", Data: "hi alice"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	for name, w := range map[string]*httptest.ResponseRecorder{"tab1": tab1, "tab2": tab2} {
+		if !strings.Contains(w.Body.String(), "data: hi alice") {
+			t.Errorf("Expected %s to receive the targeted broadcast", name)
+		}
+	}
+
+	if strings.Contains(other.Body.String(), "hi alice") {
+		t.Error("Other identity should not receive the targeted broadcast")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	config := DefaultConfig()
+	config.Authorize = func(r *http.Request) (string, bool) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer alice":
+			return "user-alice", true
+		case "Bearer anonymous":
+			return "", true
+		default:
+			return "", false
+		}
+	}
+	config.ClientIDFunc = func(*http.Request) string { return "from-id-func" }
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	request := func(auth string) *http.Request {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		req.Header.Set("Authorization", auth)
+		return req
+	}
+
+	// Refused connections return straight away
+	denied := httptest.NewRecorder()
+	server.HandleSSE(denied, request("Bearer mallory"))
+	if denied.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a refused connection, got %d", denied.Code)
+	}
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Fatalf("Expected no client registered, got %d", count)
+	}
+
+	go server.HandleSSE(httptest.NewRecorder(), request("Bearer alice"))
+	go server.HandleSSE(httptest.NewRecorder(), request("Bearer anonymous"))
+	time.Sleep(100 * time.Millisecond)
+
+	if !server.HasClient("user-alice") {
+		t.Error("Expected the ID chosen by Authorize to be used")
+	}
+	if !server.HasClient("from-id-func") {
+		t.Error("Expected an empty ID from Authorize to fall back to ClientIDFunc")
+	}
+}
+
+// http10Request sends a raw HTTP/1.0 request to the server at addr and
+// returns the parsed response
+func http10Request(t *testing.T, addr string) (net.Conn, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if _, err := conn.Write([]byte("GET /events HTTP/1.0\r\nHost: " + addr + "\r\n\r\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Reading response failed: %v", err)
+	}
+	return conn, resp
+}
+
+func TestHTTP10StreamsUntilClose(t *testing.T) {
+	server := NewServer()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSSE))
+	defer ts.Close()
+
+	conn, resp := http10Request(t, ts.Listener.Addr().String())
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if len(resp.TransferEncoding) != 0 {
+		t.Errorf("Expected no transfer encoding for HTTP/1.0, got %v", resp.TransferEncoding)
+	}
+	if got := resp.Header.Get("Connection"); got != "close" {
+		t.Errorf("Expected Connection: close, got %q", got)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	server.Broadcast(Event{Type: "tick", Data: "over-http10"})
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+
+	// The stream ends when the server closes the connection
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading the stream failed: %v", err)
+	}
+	if !strings.Contains(string(body), "data: over-http10") {
+		t.Errorf("Expected the broadcast on the HTTP/1.0 stream, got %q", body)
+	}
+}
+
+func TestRejectHTTP10(t *testing.T) {
+	config := DefaultConfig()
+	config.RejectHTTP10 = true
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSSE))
+	defer ts.Close()
+
+	conn, resp := http10Request(t, ts.Listener.Addr().String())
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("Expected 505, got %d", resp.StatusCode)
+	}
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected no client registered, got %d", count)
+	}
+}
+
+func TestDuplicateClientIDPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DuplicateIDPolicy
+	}{
+		{"reject new", DuplicateRejectNew},
+		{"replace old", DuplicateReplaceOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ClientIDFunc = func(r *http.Request) string {
+				return r.URL.Query().Get("id")
+			}
+			config.DuplicateIDPolicy = tt.policy
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			oldReq := httptest.NewRequest("GET", "/events?id=tab", http.NoBody)
+			oldW := httptest.NewRecorder()
+			oldDone := make(chan struct{})
+			go func() {
+				server.HandleSSE(oldW, oldReq)
+				close(oldDone)
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+
+			newReq := httptest.NewRequest("GET", "/events?id=tab", http.NoBody)
+			newW := httptest.NewRecorder()
+			newDone := make(chan struct{})
+			go func() {
+				server.HandleSSE(newW, newReq)
+				close(newDone)
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+
+			if count := server.GetConnectionCount(); count != 1 {
+				t.Errorf("Expected 1 registered connection, got %d", count)
+			}
+
+			switch tt.policy {
+			case DuplicateRejectNew:
+				<-newDone
+				if newW.Code != http.StatusConflict {
+					t.Errorf("Expected new connection to get 409, got %d", newW.Code)
+				}
+				select {
+				case <-oldDone:
+					t.Error("Old connection should stay open")
+				default:
+				}
+			case DuplicateReplaceOld:
+				<-oldDone
+				select {
+				case <-newDone:
+					t.Error("New connection should stay open")
+				default:
+				}
+
+				// The old connection's cleanup must not remove the new one
+				server.Broadcast(Event{Type: "after", Data: "replaced"})
+				time.Sleep(100 * time.Millisecond)
+				if count := server.GetConnectionCount(); count != 1 {
+					t.Errorf("Expected replacement to stay registered, got %d", count)
+				}
+			}
+		})
+	}
+}
+
+func TestCoalesceHeartbeat(t *testing.T) {
+	config := DefaultConfig()
+	config.HeartbeatInterval = 100 * time.Millisecond
+	config.CoalesceHeartbeat = true
+	config.IdentityFunc = func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}
+	server := NewServerWithConfig(config)
+
+	activeReq := httptest.NewRequest("GET", "/events", http.NoBody)
+	activeReq.Header.Set("X-User", "active")
+	active := httptest.NewRecorder()
+
+	idle := httptest.NewRecorder()
+
+	go server.HandleSSE(active, activeReq)
+	go server.HandleSSE(idle, httptest.NewRequest("GET", "/events", http.NoBody))
+
+	// Keep the active client busy for several heartbeat intervals
+	deadline := time.Now().Add(450 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		server.BroadcastToIdentity("active", Event{Type: "update", Data: "busy"})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	server.Shutdown()
+
+	if strings.Contains(active.Body.String(), "event: heartbeat") {
+		t.Error("Active client should not receive heartbeats")
+	}
+	if !strings.Contains(idle.Body.String(), "event: heartbeat") {
+		t.Error("Idle client should receive heartbeats")
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	data := map[string]interface{}{"name": "widget", "count": 2}
+
+	tests := []struct {
+		name   string
+		pretty bool
+		want   string
+	}{
+		{"enabled", true, "data: {\ndata:   \"count\": 2,\ndata:   \"name\": \"widget\"\ndata: }\n\n"},
+		{"disabled", false, "data: {\"count\":2,\"name\":\"widget\"}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.PrettyJSON = tt.pretty
+			server := NewServerWithConfig(config)
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			w := httptest.NewRecorder()
+
+			go server.HandleSSE(w, req)
+
+			time.Sleep(100 * time.Millisecond)
+			server.Broadcast(Event{Type: "state", Data: data})
+			time.Sleep(100 * time.Millisecond)
+			server.Shutdown()
+
+			body := w.Body.String()
+			if !strings.Contains(body, "event: state\n"+tt.want) {
+				t.Errorf("Expected %q in response, got %q", tt.want, body)
+			}
+		})
+	}
+}
+
+// flushErrWriter is a ResponseRecorder whose flushes fail once broken
+type flushErrWriter struct {
+	*httptest.ResponseRecorder
+	broken atomic.Bool
+}
+
+func (w *flushErrWriter) FlushError() error {
+	if w.broken.Load() {
+		return errors.New("connection reset")
+	}
+	w.ResponseRecorder.Flush()
+	return nil
+}
+
+func TestFlushErrorRemovesClient(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	w := &flushErrWriter{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Fatalf("Expected 1 connection, got %d", count)
+	}
+
+	w.broken.Store(true)
+	server.Broadcast(Event{Type: "tick", Data: "after break"})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected handler to return after flush failure")
+	}
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected client removed after flush failure, got %d connections", count)
+	}
+}
+
+func TestCoalescedEventsFlushedOnClose(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(server *Server, clientID string, cancel context.CancelFunc)
+	}{
+		{"close client", func(server *Server, clientID string, _ context.CancelFunc) {
+			if !server.CloseClient(clientID) {
+				t.Errorf("Expected client %s to be found", clientID)
+			}
+		}},
+		{"context canceled", func(_ *Server, _ string, cancel context.CancelFunc) {
+			cancel()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.FlushInterval = time.Hour
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				server.HandleSSE(w, req)
+				close(done)
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+
+			server.mu.RLock()
+			var client *Client
+			for _, c := range server.clients {
+				client = c
+			}
+			server.mu.RUnlock()
+			if client == nil {
+				t.Fatal("Expected a connected client")
+			}
+
+			for i := 0; i < 3; i++ {
+				server.Broadcast(Event{Type: "tick", ID: fmt.Sprintf("e%d", i), Data: i})
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			client.mu.Lock()
+			buffered := client.out.Buffered()
+			client.mu.Unlock()
+			if buffered == 0 {
+				t.Fatal("Expected events to be held in the coalescing buffer")
+			}
+
+			tt.close(server, client.ID, cancel)
+			<-done
+
+			body := w.Body.String()
+			for i := 0; i < 3; i++ {
+				if !strings.Contains(body, fmt.Sprintf("id: e%d\nevent: tick\n", i)) {
+					t.Errorf("Expected buffered event e%d to be flushed, got %q", i, body)
+				}
+			}
+			assertWellFramed(t, body)
+		})
+	}
+}
+
+func TestEventMetaComments(t *testing.T) {
+	server := NewServer()
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Broadcast(Event{
+		Type: "order",
+		ID:   "42",
+		Data: "shipped",
+		Meta: map[string]string{"trace_id": "abc123", "priority": "high", "note": "two\nlines"},
+	})
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	want := ": note=two lines\n: priority=high\n: trace_id=abc123\nid: 42\nevent: order\ndata: shipped\n\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("Expected %q in response, got %q", want, body)
+	}
+	assertWellFramed(t, body)
+}
+
+func TestWaitBlocksUntilHandlersExit(t *testing.T) {
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	server := NewServerWithConfig(config)
+
+	writers := connectStalled(server, 3)
+
+	waited := make(chan struct{})
+	go func() {
+		server.Wait()
+		close(waited)
+	}()
+
+	// Handlers block writing their close events while stalled
+	go server.Shutdown()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while handlers were still running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, w := range writers {
+		w.unstall()
+	}
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after handlers exited")
+	}
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections after Wait, got %d", count)
+	}
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	server.HandleSSE(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 after shutdown, got %d", w.Code)
+	}
+}
+
+func TestOnConnectBroadcastIsReentrant(t *testing.T) {
+	config := DefaultConfig()
+	var server *Server
+	config.OnConnect = func(info *ClientInfo) {
+		server.Broadcast(Event{Type: "welcome", Data: info.ID})
+	}
+	server = NewServerWithConfig(config)
+
+	const clients = 3
+	writers := make([]*httptest.ResponseRecorder, clients)
+	done := make(chan struct{}, clients)
+	for i := range writers {
+		writers[i] = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go func(w *httptest.ResponseRecorder) {
+			server.HandleSSE(w, req)
+			done <- struct{}{}
+		}(writers[i])
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if count := server.GetConnectionCount(); count != clients {
+		t.Fatalf("Expected %d connections, got %d", clients, count)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	server.Shutdown()
+	for i := 0; i < clients; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Handler deadlocked after reentrant broadcast")
+		}
+	}
+
+	// Each client sees its own welcome and those of everyone who joined later
+	for i, w := range writers {
+		if got, want := strings.Count(w.Body.String(), "event: welcome\n"), clients-i; got != want {
+			t.Errorf("Client %d: expected %d welcome events, got %d", i, want, got)
+		}
+	}
+}
+
+func TestBroadcastDuringContextCancellation(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferSize = 1
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	stop := make(chan struct{})
+	var broadcasters sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		broadcasters.Add(1)
+		go func(i int) {
+			defer broadcasters.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// A send on a closed channel would panic and crash the test
+				switch j % 3 {
+				case 0:
+					server.Broadcast(Event{Type: "tick", Data: j})
+				case 1:
+					server.BroadcastDeadline(Event{Type: "tick", Data: j}, time.Millisecond)
+				default:
+					server.SetSticky(fmt.Sprintf("key-%d", i), Event{Type: "state", Data: j})
+				}
+			}
+		}(i)
+	}
+
+	var handlers sync.WaitGroup
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 25; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				server.HandleSSE(httptest.NewRecorder(), req)
+			}()
+			time.AfterFunc(time.Duration(i%5)*time.Millisecond, cancel)
+		}
+		handlers.Wait()
+	}
+
+	close(stop)
+	broadcasters.Wait()
+
+	// Removals triggered by full buffers run asynchronously
+	time.Sleep(50 * time.Millisecond)
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected all canceled clients removed, got %d", count)
+	}
+}
+
+func TestEventWireFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"id without type", Event{ID: "42", Data: "x"}, "id: 42\ndata: x\n\n"},
+		{"id and type", Event{ID: "42", Type: "update", Data: "x"}, "id: 42\nevent: update\ndata: x\n\n"},
+		{"data only", Event{Data: "x"}, "data: x\n\n"},
+		{"multi-line data", Event{Data: "line1\nline2"}, "data: line1\ndata: line2\n\n"},
+		{"trailing newline", Event{Data: "line1\n"}, "data: line1\ndata: \n\n"},
+		{"meta before fields", Event{ID: "42", Data: "x", Meta: map[string]string{"trace": "t1"}}, ": trace=t1\nid: 42\ndata: x\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			defer server.Shutdown()
+
+			w := httptest.NewRecorder()
+			client := &Client{ID: "c1", conn: w, flusher: http.NewResponseController(w), server: server}
+
+			if err := server.sendEventToClient(client, tt.event); err != nil {
+				t.Fatalf("sendEventToClient failed: %v", err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("Expected wire output %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldInjectionNeutralized(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"CR in type", Event{Type: "evil\rdata: injected", Data: "x"}, "event: evil data: injected\ndata: x\n\n"},
+		{"CRLF in ID", Event{ID: "1\r\nevent: fake", Data: "x"}, "id: 1  event: fake\ndata: x\n\n"},
+		{"CR in meta", Event{Meta: map[string]string{"k": "v\rdata: injected"}, Data: "x"}, ": k=v data: injected\ndata: x\n\n"},
+		{"CR in data", Event{Data: "a\revent: fake\r\nb"}, "data: a\ndata: event: fake\ndata: b\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			defer server.Shutdown()
+
+			w := httptest.NewRecorder()
+			client := &Client{ID: "c1", conn: w, flusher: http.NewResponseController(w), server: server}
+
+			if err := server.sendEventToClient(client, tt.event); err != nil {
+				t.Fatalf("sendEventToClient failed: %v", err)
+			}
+
+			body := w.Body.String()
+			if body != tt.want {
+				t.Errorf("Expected wire output %q, got %q", tt.want, body)
+			}
+			// Clients break lines on CR as well as LF
+			assertWellFramed(t, strings.ReplaceAll(body, "\r", "\n"))
+		})
+	}
+}
+
+func TestDataOnly(t *testing.T) {
+	config := DefaultConfig()
+	config.DataOnly = true
+	config.PrettyJSON = true
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+	go server.HandleSSE(w, req)
+
+	time.Sleep(100 * time.Millisecond)
+	server.Broadcast(Event{Type: "order", ID: "7", Data: map[string]int{"qty": 2}})
+	server.Broadcast(Event{Data: "plain"})
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	for _, field := range []string{"event: ", "id: "} {
+		if strings.Contains(body, field) {
+			t.Errorf("Expected no %q lines in data-only mode, got %q", field, body)
+		}
+	}
+
+	for _, want := range []string{
+		"data: {\"type\":\"order\",\"id\":\"7\",\"data\":{\"qty\":2}}\n\n",
+		"data: {\"data\":\"plain\"}\n\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in response, got %q", want, body)
+		}
+	}
+}
+
+func TestRenameType(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	renamed := []*Client{
+		addBareClient(server, "c1", 10),
+		addBareClient(server, "c2", 10),
+	}
+	existing := addBareClient(server, "c3", 10)
+
+	server.mu.Lock()
+	server.clientsByType["notif"] = map[string]*Client{}
+	for _, client := range renamed {
+		client.Type = "notif"
+		server.clientsByType["notif"][client.ID] = client
+	}
+	existing.Type = "notification"
+	server.clientsByType["notification"] = map[string]*Client{existing.ID: existing}
+	server.mu.Unlock()
+
+	server.RenameType("notif", "notification")
+
+	server.mu.RLock()
+	_, oldExists := server.clientsByType["notif"]
+	subscribers := len(server.clientsByType["notification"])
+	server.mu.RUnlock()
+
+	if oldExists {
+		t.Error("Expected the old type bucket to be removed")
+	}
+	if subscribers != 3 {
+		t.Errorf("Expected 3 subscribers under the new type, got %d", subscribers)
+	}
+
+	server.BroadcastToType("notification", Event{Type: "notification", Data: "hi"})
+
+	for _, client := range append(renamed, existing) {
+		if client.Type != "notification" {
+			t.Errorf("Expected client %s type to be renamed, got %q", client.ID, client.Type)
+		}
+		select {
+		case event := <-client.EventCh:
+			if event.Data != "hi" {
+				t.Errorf("Expected client %s to receive the event, got %+v", client.ID, event)
+			}
+		default:
+			t.Errorf("Expected client %s to receive the broadcast for the new type", client.ID)
+		}
+	}
+}
+
+// trackingBody records how much of a request body was read and whether it was closed
+type trackingBody struct {
+	*strings.Reader
+	closed atomic.Bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+func TestRequestBodyDrained(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	tests := []struct {
+		name   string
+		origin string
+	}{
+		{"normal disconnect", ""},
+		{"rejected request", "https://blocked.example"},
+	}
+	server.SetAllowedOrigins([]string{"https://allowed.example"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackingBody{Reader: strings.NewReader(strings.Repeat("x", 4096))}
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest("GET", "/events", body).WithContext(ctx)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				server.HandleSSE(httptest.NewRecorder(), req)
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			<-done
+
+			if body.Len() != 0 {
+				t.Errorf("Expected the body to be drained, %d bytes left", body.Len())
+			}
+			if !body.closed.Load() {
+				t.Error("Expected the body to be closed")
+			}
+			if count := server.GetConnectionCount(); count != 0 {
+				t.Errorf("Expected no clients left, got %d", count)
+			}
+		})
+	}
+}
+
+func TestReconnectHintHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		wantRetry     string
+		wantHeartbeat string
+	}{
+		{"enabled", true, "5000", "15000"},
+		{"disabled", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.RetryTimeout = 5000
+			config.HeartbeatInterval = 15 * time.Second
+			config.ReconnectHintHeaders = tt.enabled
+			server := NewServerWithConfig(config)
+			defer server.Shutdown()
+
+			req := httptest.NewRequest("GET", "/events", http.NoBody)
+			ctx, cancel := context.WithCancel(req.Context())
+			cancel()
+			w := httptest.NewRecorder()
+			server.HandleSSE(w, req.WithContext(ctx))
+
+			if got := w.Header().Get("X-SSE-Retry"); got != tt.wantRetry {
+				t.Errorf("Expected X-SSE-Retry %q, got %q", tt.wantRetry, got)
+			}
+			if got := w.Header().Get("X-SSE-Heartbeat-Interval"); got != tt.wantHeartbeat {
+				t.Errorf("Expected X-SSE-Heartbeat-Interval %q, got %q", tt.wantHeartbeat, got)
+			}
+		})
+	}
+}
+
+// blockingFlushWriter blocks flushes once armed until its write deadline
+// passes, like a connection whose TCP window stays full
+type blockingFlushWriter struct {
+	*httptest.ResponseRecorder
+	mu       sync.Mutex
+	armed    bool
+	deadline time.Time
+}
+
+func (w *blockingFlushWriter) arm() {
+	w.mu.Lock()
+	w.armed = true
+	w.mu.Unlock()
+}
+
+func (w *blockingFlushWriter) SetWriteDeadline(deadline time.Time) error {
+	w.mu.Lock()
+	w.deadline = deadline
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *blockingFlushWriter) FlushError() error {
+	w.mu.Lock()
+	armed, deadline := w.armed, w.deadline
+	w.mu.Unlock()
+
+	if !armed {
+		w.ResponseRecorder.Flush()
+		return nil
+	}
+	if deadline.IsZero() {
+		// No deadline would block forever; give up so the test cannot hang
+		deadline = time.Now().Add(2 * time.Second)
+	}
+	time.Sleep(time.Until(deadline))
+	return os.ErrDeadlineExceeded
+}
+
+func TestFlushTimeoutRemovesClient(t *testing.T) {
+	config := DefaultConfig()
+	config.FlushTimeout = 50 * time.Millisecond
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	w := &blockingFlushWriter{ResponseRecorder: httptest.NewRecorder()}
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	w.arm()
+	start := time.Now()
+	server.Broadcast(Event{Data: "stuck"})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timed-out flush to end the connection")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected removal shortly after the flush timeout, took %v", elapsed)
+	}
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected the client to be removed, got %d connections", count)
+	}
+}
+
+func TestRetryFieldOnHandshake(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    int
+		dataOnly bool
+		want     string
+	}{
+		{"default", 3000, false, "retry: 3000\nevent: connection\ndata: "},
+		{"custom", 750, false, "retry: 750\nevent: connection\ndata: "},
+		{"data only", 750, true, "retry: 750\ndata: "},
+		{"disabled", 0, false, "event: connection\ndata: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.RetryTimeout = tt.retry
+			config.DataOnly = tt.dataOnly
+			server := NewServerWithConfig(config)
+
+			w := httptest.NewRecorder()
+			go server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+			time.Sleep(50 * time.Millisecond)
+			server.Broadcast(Event{Type: "update", Data: "x"})
+			time.Sleep(50 * time.Millisecond)
+			server.Shutdown()
+
+			body := w.Body.String()
+			if !strings.HasPrefix(body, tt.want) {
+				t.Errorf("Expected the stream to start with %q, got %q", tt.want, body)
+			}
+			if count := strings.Count(body, "retry:"); count > 1 {
+				t.Errorf("Expected the retry field to be sent once, got %d", count)
+			}
+		})
+	}
+}
+
+func TestBeforeSendPerClient(t *testing.T) {
+	config := DefaultConfig()
+	ids := make(chan string, 2)
+	ids <- "alice"
+	ids <- "bob"
+	config.ClientIDFunc = func(*http.Request) string { return <-ids }
+	config.BeforeSend = func(clientID string, ev Event) Event {
+		if ev.Type == "greeting" {
+			ev.Data = fmt.Sprintf("%v, %s", ev.Data, clientID)
+		}
+		return ev
+	}
+	server := NewServerWithConfig(config)
+
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	done := make(chan struct{}, 2)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go func(w *httptest.ResponseRecorder) {
+			server.HandleSSE(w, req)
+			done <- struct{}{}
+		}(recorders[i])
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	server.Broadcast(Event{Type: "greeting", Data: "hello"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+	<-done
+	<-done
+
+	for i, id := range []string{"alice", "bob"} {
+		body := recorders[i].Body.String()
+		if want := "data: hello, " + id + "\n"; !strings.Contains(body, want) {
+			t.Errorf("Expected %s to see %q, got %q", id, want, body)
+		}
+	}
+}
+
+func TestGenerateClientIDUnique(t *testing.T) {
+	const (
+		workers = 16
+		perWork = 1000
+	)
+
+	results := make(chan string, workers*perWork)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWork; j++ {
+				results <- generateClientID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[string]bool, workers*perWork)
+	for id := range results {
+		if seen[id] {
+			t.Fatalf("Duplicate client ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+// slowReplayServer returns a server whose history replay of n events takes
+// delay per event, and a channel closed once replay has started
+func slowReplayServer(n int, delay time.Duration) (*Server, <-chan struct{}) {
+	started := make(chan struct{})
+	var once sync.Once
+
+	config := DefaultConfig()
+	config.HistorySize = n
+	config.BeforeSend = func(_ string, ev Event) Event {
+		if ev.Type == "past" {
+			once.Do(func() { close(started) })
+			time.Sleep(delay)
+		}
+		return ev
+	}
+	server := NewServerWithConfig(config)
+	for i := 0; i < n; i++ {
+		server.Broadcast(Event{Type: "past", Data: i})
+	}
+	return server, started
+}
+
+func TestShutdownContextWaitsForReplay(t *testing.T) {
+	const events = 40
+	server, started := slowReplayServer(events, 5*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/events?replay=all", http.NoBody)
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.ShutdownContext(ctx); err != nil {
+		t.Fatalf("Expected replay to finish within the deadline, got %v", err)
+	}
+	<-done
+
+	if got := strings.Count(w.Body.String(), "event: past\n"); got != events {
+		t.Errorf("Expected the full replay of %d events before close, got %d", events, got)
+	}
+
+	late := httptest.NewRecorder()
+	server.HandleSSE(late, httptest.NewRequest("GET", "/events", http.NoBody))
+	if late.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected connections after shutdown to be refused, got %d", late.Code)
+	}
+}
+
+func TestShutdownContextDeadline(t *testing.T) {
+	server, started := slowReplayServer(100, 20*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/events?replay=all", http.NoBody)
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.ShutdownContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	<-done
+
+	if got := strings.Count(w.Body.String(), "event: past\n"); got >= 100 {
+		t.Errorf("Expected the replay to be cut off at the deadline, got all %d events", got)
+	}
+}
+
+func TestOnDisconnect(t *testing.T) {
+	disconnected := make(chan string, 4)
+	connectedAtCallback := make(chan int, 4)
+
+	var server *Server
+	config := DefaultConfig()
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	config.DuplicateIDPolicy = DuplicateReplaceOld
+	config.OnDisconnect = func(clientID string) {
+		connectedAtCallback <- server.GetConnectionCount()
+		disconnected <- clientID
+	}
+	server = NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	connect := func() (context.CancelFunc, chan struct{}) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			server.HandleSSE(httptest.NewRecorder(), req)
+			close(done)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return cancel, done
+	}
+
+	_, firstDone := connect()
+	cancel, secondDone := connect()
+	<-firstDone
+
+	select {
+	case id := <-disconnected:
+		t.Fatalf("Expected no OnDisconnect for a replaced connection, got %q", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	<-secondDone
+
+	select {
+	case id := <-disconnected:
+		if id != "c1" {
+			t.Errorf("Expected OnDisconnect for c1, got %q", id)
+		}
+		if n := <-connectedAtCallback; n != 0 {
+			t.Errorf("Expected the client to be removed before OnDisconnect, %d still connected", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnDisconnect after the client went away")
+	}
+}
+
+type traceKey struct{}
+
+func TestBroadcastCtxMiddleware(t *testing.T) {
+	config := DefaultConfig()
+	config.EventMiddleware = func(ctx context.Context, event Event) Event {
+		trace, _ := ctx.Value(traceKey{}).(string)
+		if trace == "" {
+			trace = "none"
+		}
+		event.Meta = map[string]string{"trace": trace}
+		return event
+	}
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events?types=chat", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "t-123")
+	server.BroadcastCtx(ctx, Event{Type: "update", Data: "with ctx"})
+	server.Broadcast(Event{Type: "update", Data: "without ctx"})
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "typed"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+	<-done
+
+	body := w.Body.String()
+	for _, want := range []string{
+		": trace=t-123\nevent: update\ndata: with ctx\n",
+		": trace=none\nevent: update\ndata: without ctx\n",
+		": trace=none\nevent: chat\ndata: typed\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in stream, got %q", want, body)
+		}
+	}
+}
+
+func TestLegacyBrowserCompat(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			config := DefaultConfig()
+			config.LegacyBrowserCompat = enabled
+			server := NewServerWithConfig(config)
+
+			w := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			server.Broadcast(Event{Type: "update", Data: "x"})
+			time.Sleep(50 * time.Millisecond)
+			server.Shutdown()
+			<-done
+
+			body := w.Body.String()
+			if got := strings.HasPrefix(body, "\xEF\xBB\xBFretry: "); got != enabled {
+				t.Errorf("Expected BOM leading the stream=%v, got %q", enabled, body)
+			}
+			if strings.Count(body, "\xEF\xBB\xBF") > 1 {
+				t.Errorf("Expected the BOM to be written once, got %q", body)
+			}
+			if got := w.Header().Get("X-Content-Type-Options") == "nosniff"; got != enabled {
+				t.Errorf("Expected nosniff header=%v, got %q", enabled, w.Header().Get("X-Content-Type-Options"))
+			}
+		})
+	}
+}
+
+func TestShutdownContextDrainsBuffers(t *testing.T) {
+	const events = 20
+
+	config := DefaultConfig()
+	config.SendCloseEvent = true
+	server := NewServerWithConfig(config)
+
+	w := newStallWriter()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	w.stall()
+	for i := 0; i < events; i++ {
+		server.Broadcast(Event{Type: "queued", Data: i})
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		w.unstall()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.ShutdownContext(ctx); err != nil {
+		t.Fatalf("Expected buffers to drain within the deadline, got %v", err)
+	}
+	<-done
+
+	body := w.Body.String()
+	if got := strings.Count(body, "event: queued\n"); got != events {
+		t.Errorf("Expected all %d queued events before close, got %d", events, got)
+	}
+	closeAt := strings.Index(body, "event: close\n")
+	if closeAt < 0 || closeAt < strings.LastIndex(body, "event: queued\n") {
+		t.Errorf("Expected a final close event after the queued events, got %q", body)
+	}
+}
+
+func TestShutdownContextSendsCloseEvent(t *testing.T) {
+	// SendCloseEvent is off, ShutdownContext notifies clients regardless
+	server := NewServer()
+
+	w := newStallWriter()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.ShutdownContext(ctx); err != nil {
+		t.Fatalf("Expected a clean shutdown, got %v", err)
+	}
+	<-done
+
+	if body := w.Body.String(); !strings.Contains(body, "event: close\n") || !strings.Contains(body, `"reason":"shutdown"`) {
+		t.Errorf("Expected a final close event with reason shutdown, got %q", body)
+	}
+}
+
+func TestShutdownContextDrainDeadline(t *testing.T) {
+	server := NewServer()
+
+	w := newStallWriter()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	w.stall()
+	for i := 0; i < 5; i++ {
+		server.Broadcast(Event{Type: "queued", Data: i})
+	}
+
+	// Closing waits for the stalled write, so release it after the deadline
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		w.unstall()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := server.ShutdownContext(ctx)
+	<-done
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded while buffers were stuck, got %v", err)
+	}
+	if server.GetConnectionCount() != 0 {
+		t.Error("Expected the server to be shut down after the deadline")
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	server := NewServer()
+
+	// Create test connection
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	event := Event{
+		Type: "benchmark",
+		Data: "test data",
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		server.Broadcast(event)
+	}
+
+	server.Shutdown()
+}
+
+func BenchmarkMultipleConnections(b *testing.B) {
+	server := NewServer()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		w := httptest.NewRecorder()
+
+		go func() {
+			server.HandleSSE(w, req)
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+}
+
+func BenchmarkBroadcastContention(b *testing.B) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	for i := 0; i < 1000; i++ {
+		client := addBareClient(server, fmt.Sprintf("client-%d", i), 64)
+		go func() {
+			for range client.EventCh {
+			}
+		}()
+	}
+
+	// Churn registrations so broadcasts compete with writers for the lock
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			id := fmt.Sprintf("churn-%d", i)
+			addBareClient(server, id, 64)
+			server.removeClient(id)
+		}
+	}()
+
+	event := Event{Type: "benchmark", Data: "test data"}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			server.broadcast(event)
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
 }
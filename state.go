@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// StateSnapshot is the data of a "state" event, the full state for a key
+type StateSnapshot struct {
+	Key   string      `json:"key"`
+	State interface{} `json:"state"`
+}
+
+// StatePatch is the data of a "state-patch" event, an RFC 7386 JSON merge
+// patch from the previous state for a key to the current one
+type StatePatch struct {
+	Key   string      `json:"key"`
+	Patch interface{} `json:"patch"`
+}
+
+// PublishState records state as the current state for key and sends
+// connected clients only what changed: a "state-patch" event holding a JSON
+// merge patch against the previous state. The first state for a key is sent
+// in full as a "state" event, which is also kept as a sticky event so new
+// connections start from the full current state. Publishing an unchanged
+// state sends nothing. As with any merge patch, a field set to null is
+// indistinguishable from a removed one.
+func (s *Server) PublishState(key string, state interface{}) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMarshalFailed, err)
+	}
+	// Diff the JSON form so the patch matches what clients decode
+	var current interface{}
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return fmt.Errorf("%w: %v", ErrMarshalFailed, err)
+	}
+
+	// Publishes are serialized so clients apply patches in order
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	full := Event{Type: "state", Data: StateSnapshot{Key: key, State: current}}
+
+	// Clients registered after this cut get the new snapshot, earlier ones the patch
+	s.mu.Lock()
+	previous, known := s.states[key]
+	s.states[key] = current
+	s.setStickyLocked(stateStickyKey(key), full)
+	clients := s.clientsLocked()
+	s.mu.Unlock()
+
+	event := full
+	if known {
+		patch, changed := mergePatch(previous, current)
+		if !changed {
+			return nil
+		}
+		event = Event{Type: "state-patch", Data: StatePatch{Key: key, Patch: patch}}
+	}
+
+	s.fanOut(clients, event, nil)
+	return nil
+}
+
+// stateStickyKey namespaces state snapshots among the sticky events
+func stateStickyKey(key string) string {
+	return "state:" + key
+}
+
+// mergePatch returns the JSON merge patch turning previous into current and
+// whether they differ. Both must be decoded JSON values.
+func mergePatch(previous, current interface{}) (interface{}, bool) {
+	prevObj, prevIsObj := previous.(map[string]interface{})
+	curObj, curIsObj := current.(map[string]interface{})
+	if !prevIsObj || !curIsObj {
+		if reflect.DeepEqual(previous, current) {
+			return nil, false
+		}
+		return current, true
+	}
+
+	patch := make(map[string]interface{})
+	for key, value := range curObj {
+		old, exists := prevObj[key]
+		if !exists {
+			patch[key] = value
+			continue
+		}
+		if p, changed := mergePatch(old, value); changed {
+			patch[key] = p
+		}
+	}
+	for key := range prevObj {
+		if _, exists := curObj[key]; !exists {
+			patch[key] = nil
+		}
+	}
+	return patch, len(patch) > 0
+}
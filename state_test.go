@@ -0,0 +1,99 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishState(t *testing.T) {
+	server := NewServer()
+
+	existing := httptest.NewRecorder()
+	go server.HandleSSE(existing, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+
+	first := map[string]interface{}{"score": 1, "players": []string{"ana"}, "paused": true}
+	second := map[string]interface{}{"score": 2, "players": []string{"ana"}}
+	if err := server.PublishState("game", first); err != nil {
+		t.Fatalf("PublishState failed: %v", err)
+	}
+	if err := server.PublishState("game", second); err != nil {
+		t.Fatalf("PublishState failed: %v", err)
+	}
+	if err := server.PublishState("game", second); err != nil {
+		t.Fatalf("PublishState failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	newcomer := httptest.NewRecorder()
+	go server.HandleSSE(newcomer, httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := existing.Body.String()
+	wantFull := `data: {"key":"game","state":{"paused":true,"players":["ana"],"score":1}}`
+	if !strings.Contains(body, "event: state\n"+wantFull+"\n") {
+		t.Errorf("Expected the first state in full, got %q", body)
+	}
+	wantPatch := `data: {"key":"game","patch":{"paused":null,"score":2}}`
+	if !strings.Contains(body, "event: state-patch\n"+wantPatch+"\n") {
+		t.Errorf("Expected a merge patch for the second state, got %q", body)
+	}
+	if count := strings.Count(body, "event: state-patch\n"); count != 1 {
+		t.Errorf("Expected an unchanged state to send nothing, got %d patches", count)
+	}
+
+	body = newcomer.Body.String()
+	wantSnapshot := `data: {"key":"game","state":{"players":["ana"],"score":2}}`
+	if !strings.Contains(body, "event: state\n"+wantSnapshot+"\n") {
+		t.Errorf("Expected the newcomer to get the full current state, got %q", body)
+	}
+	if strings.Contains(body, "state-patch") || strings.Count(body, "event: state\n") != 1 {
+		t.Errorf("Expected only the current snapshot for the newcomer, got %q", body)
+	}
+}
+
+func TestPublishStateMarshalError(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if err := server.PublishState("bad", make(chan int)); !errors.Is(err, ErrMarshalFailed) {
+		t.Errorf("Expected ErrMarshalFailed, got %v", err)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    interface{}
+		current     interface{}
+		wantChanged bool
+		wantPatch   interface{}
+	}{
+		{"equal", map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 1.0}, false, nil},
+		{"nested change", map[string]interface{}{"a": map[string]interface{}{"b": 1.0, "c": 2.0}},
+			map[string]interface{}{"a": map[string]interface{}{"b": 1.0, "c": 3.0}}, true,
+			map[string]interface{}{"a": map[string]interface{}{"c": 3.0}}},
+		{"added and removed", map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 2.0}, true,
+			map[string]interface{}{"a": nil, "b": 2.0}},
+		{"array replaced", []interface{}{1.0}, []interface{}{1.0, 2.0}, true, []interface{}{1.0, 2.0}},
+		{"scalar", "x", "y", true, "y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, changed := mergePatch(tt.previous, tt.current)
+			if changed != tt.wantChanged {
+				t.Fatalf("Expected changed=%v, got %v", tt.wantChanged, changed)
+			}
+			if changed && !reflect.DeepEqual(patch, tt.wantPatch) {
+				t.Errorf("Expected patch %v, got %v", tt.wantPatch, patch)
+			}
+		})
+	}
+}
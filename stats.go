@@ -0,0 +1,35 @@
+package sse
+
+// Stats is a point-in-time snapshot of server metrics
+type Stats struct {
+	Connections   int     `json:"connections"`
+	TotalBuffered int     `json:"total_buffered"`
+	OccupancyP95  float64 `json:"occupancy_p95"` // 95th percentile of per-client peak buffer fill ratio, 0 to 1
+
+	// Counters since the server was created, for export as Prometheus counters
+	TotalConnectionsAccepted int64 `json:"total_connections_accepted"`
+	RejectedConnections      int64 `json:"rejected_connections"` // refused at MaxConnections
+	TotalEventsSent          int64 `json:"total_events_sent"`    // written to clients, including heartbeats
+	TotalEventsDropped       int64 `json:"total_events_dropped"` // not queued, or evicted by OverflowDropOldest, because a client's buffer was full
+}
+
+// Stats returns current server metrics. OccupancyP95 is only populated when
+// Config.OccupancySampleInterval is set.
+func (s *Server) Stats() Stats {
+	s.mu.RLock()
+	stats := Stats{
+		Connections:   len(s.clients),
+		TotalBuffered: s.totalBufferedLocked(),
+	}
+	s.mu.RUnlock()
+
+	stats.TotalConnectionsAccepted = s.accepted.Load()
+	stats.RejectedConnections = s.rejected.Load()
+	stats.TotalEventsSent = s.eventsSent.Load()
+	stats.TotalEventsDropped = s.eventsDropped.Load()
+
+	if s.occupancy != nil {
+		stats.OccupancyP95 = s.occupancy.p95()
+	}
+	return stats
+}
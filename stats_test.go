@@ -0,0 +1,57 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatsCounters(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnections = 2
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+		server.HandleSSE(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	addBareClient(server, "bare", 1)
+	rejected := httptest.NewRecorder()
+	server.HandleSSE(rejected, httptest.NewRequest("GET", "/events", http.NoBody))
+	if rejected.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the connection over MaxConnections to be refused, got %d", rejected.Code)
+	}
+
+	server.Broadcast(Event{Data: "fits"})
+	server.Broadcast(Event{Data: "overflows the bare client"})
+	time.Sleep(100 * time.Millisecond)
+
+	stats := server.Stats()
+	cancel()
+	<-done
+
+	if stats.TotalConnectionsAccepted != 1 {
+		t.Errorf("Expected 1 accepted connection, got %d", stats.TotalConnectionsAccepted)
+	}
+	if stats.RejectedConnections != 1 {
+		t.Errorf("Expected 1 rejected connection, got %d", stats.RejectedConnections)
+	}
+	// The connection event and both broadcasts reach the streaming client
+	if stats.TotalEventsSent != 3 {
+		t.Errorf("Expected 3 events sent, got %d", stats.TotalEventsSent)
+	}
+	if stats.TotalEventsDropped != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", stats.TotalEventsDropped)
+	}
+	if after := server.Stats(); after.TotalConnectionsAccepted != 1 || after.Connections != 0 {
+		t.Errorf("Expected counters to survive disconnects, got %+v", after)
+	}
+}
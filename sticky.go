@@ -0,0 +1,44 @@
+package sse
+
+// SetSticky stores a "current state" event under key. Every new connection
+// receives all sticky events right after the connection event, and connected
+// clients receive the event immediately, with full buffers handled by
+// Config.OverflowPolicy as for broadcasts. Setting an existing key replaces
+// its event in place.
+func (s *Server) SetSticky(key string, event Event) {
+	event.Data = snapshotData(event.Data)
+
+	s.mu.Lock()
+	s.setStickyLocked(key, event)
+	clients := s.clientsLocked()
+	s.mu.Unlock()
+
+	s.fanOut(clients, event, nil)
+}
+
+// setStickyLocked stores event under key. Callers must hold s.mu.
+func (s *Server) setStickyLocked(key string, event Event) {
+	if _, exists := s.sticky[key]; !exists {
+		s.stickyKeys = append(s.stickyKeys, key)
+	}
+	s.sticky[key] = event
+}
+
+// clientsLocked returns a copy of the connected clients. Callers must hold s.mu.
+func (s *Server) clientsLocked() []*Client {
+	clients := make([]*Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// stickyEventsLocked returns the sticky events in the order their keys were
+// first set. Callers must hold s.mu.
+func (s *Server) stickyEventsLocked() []Event {
+	events := make([]Event, 0, len(s.stickyKeys))
+	for _, key := range s.stickyKeys {
+		events = append(events, s.sticky[key])
+	}
+	return events
+}
@@ -0,0 +1,74 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetSticky(t *testing.T) {
+	server := NewServer()
+
+	server.SetSticky("config", Event{Type: "config", Data: "v1"})
+	server.SetSticky("status", Event{Type: "status", Data: "green"})
+
+	first := httptest.NewRecorder()
+	go server.HandleSSE(first, httptest.NewRequest("GET", "/events", http.NoBody))
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Replacing a key updates connected clients and later connections
+	server.SetSticky("config", Event{Type: "config", Data: "v2"})
+
+	time.Sleep(50 * time.Millisecond)
+
+	second := httptest.NewRecorder()
+	go server.HandleSSE(second, httptest.NewRequest("GET", "/events", http.NoBody))
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	body := first.Body.String()
+	if strings.Count(body, "data: v1") != 1 || strings.Count(body, "data: green") != 1 {
+		t.Errorf("Expected each sticky once on connect, got %q", body)
+	}
+	if !strings.Contains(body, "data: v2") {
+		t.Error("Expected connected client to receive updated sticky")
+	}
+	if strings.Index(body, "event: connection") > strings.Index(body, "data: v1") {
+		t.Error("Expected sticky events after the connection event")
+	}
+
+	body = second.Body.String()
+	if strings.Contains(body, "data: v1") {
+		t.Error("Replaced sticky should not be delivered to new connections")
+	}
+	if strings.Count(body, "data: v2") != 1 || strings.Count(body, "data: green") != 1 {
+		t.Errorf("Expected current stickies once on connect, got %q", body)
+	}
+	if strings.Index(body, "data: v2") > strings.Index(body, "data: green") {
+		t.Error("Expected stickies in the order their keys were first set")
+	}
+}
+
+func TestSetStickyFollowsOverflowPolicy(t *testing.T) {
+	config := DefaultConfig()
+	config.OverflowPolicy = OverflowDropEvent
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	full := addBareClient(server, "full", 1)
+	full.EventCh <- Event{Type: "backlog"}
+
+	server.SetSticky("config", Event{Type: "config", Data: "v1"})
+	time.Sleep(50 * time.Millisecond)
+
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected the full client to be kept, got %d connections", count)
+	}
+	if dropped := server.Stats().TotalEventsDropped; dropped != 1 {
+		t.Errorf("Expected the skipped sticky to be counted as dropped, got %d", dropped)
+	}
+}
@@ -0,0 +1,87 @@
+package sse
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriber is an in-process consumer of published broadcasts
+type subscriber struct {
+	ch    chan Event
+	types map[string]bool // nil accepts every type
+}
+
+// Subscribe registers an in-process subscriber that receives published
+// broadcasts (Broadcast and BroadcastToType) whose type is one of types, or
+// all of them when none are given. A BroadcastToType event is matched by the
+// type it was routed to, as SSE clients are. The channel is closed by the returned
+// unsubscribe function, when ctx is done or on Shutdown. It holds up to
+// Config.BufferSize events; broadcasts are dropped for a subscriber that
+// falls further behind.
+func (s *Server) Subscribe(ctx context.Context, types ...string) (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, s.config.BufferSize)}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.shutdown:
+		close(sub.ch)
+		s.mu.Unlock()
+		return sub.ch, func() {}
+	default:
+		s.subscribers[sub] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.removeSubscriberLocked(sub)
+		})
+	}
+	stop := context.AfterFunc(ctx, unsubscribe)
+
+	return sub.ch, func() {
+		stop()
+		unsubscribe()
+	}
+}
+
+// publishLocal hands a published event to the in-process subscribers of
+// target, the type it was routed to, or of its own type when target is
+// empty, without blocking. Sends happen under the read lock so they cannot
+// race with a subscriber's channel being closed.
+func (s *Server) publishLocal(event Event, target string) {
+	if target == "" {
+		target = event.Type
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.subscribers {
+		if sub.types != nil && !sub.types[target] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// removeSubscriberLocked unregisters and closes a subscriber if it is still
+// registered. Callers must hold s.mu for writing.
+func (s *Server) removeSubscriberLocked(sub *subscriber) {
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.ch)
+	}
+}
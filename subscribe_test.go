@@ -0,0 +1,104 @@
+package sse
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive returns the next event from ch, failing the test on timeout
+func receive(t *testing.T, ch <-chan Event) (Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for subscriber")
+		return Event{}, false
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	events, unsubscribe := server.Subscribe(context.Background(), "order")
+
+	server.Broadcast(Event{Type: "chat", Data: "ignored"})
+	server.Broadcast(Event{Type: "order", Data: "placed"})
+
+	event, ok := receive(t, events)
+	if !ok || event.Type != "order" || event.Data != "placed" {
+		t.Errorf("Expected the order event, got %+v (open %v)", event, ok)
+	}
+
+	unsubscribe()
+	unsubscribe() // unsubscribing twice is harmless
+	server.Broadcast(Event{Type: "order", Data: "shipped"})
+
+	if event, ok := receive(t, events); ok {
+		t.Errorf("Expected channel closed after unsubscribe, got %+v", event)
+	}
+}
+
+func TestSubscribeMatchesRoutedType(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	routed, unsubscribeRouted := server.Subscribe(context.Background(), "a")
+	defer unsubscribeRouted()
+	own, unsubscribeOwn := server.Subscribe(context.Background(), "b")
+	defer unsubscribeOwn()
+
+	server.BroadcastToType("a", Event{Type: "b", Data: "routed"})
+
+	if event, ok := receive(t, routed); !ok || event.Data != "routed" {
+		t.Errorf("Expected subscribers of the routed type to receive it, got %+v", event)
+	}
+	select {
+	case event := <-own:
+		t.Errorf("Expected subscribers of the event's own type to be skipped, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribeAllTypes(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	events, unsubscribe := server.Subscribe(context.Background())
+	defer unsubscribe()
+
+	server.Broadcast(Event{Type: "chat", Data: 1})
+	server.BroadcastToType("order", Event{Type: "order", Data: 2})
+
+	for _, want := range []string{"chat", "order"} {
+		if event, _ := receive(t, events); event.Type != want {
+			t.Errorf("Expected %s event, got %+v", want, event)
+		}
+	}
+}
+
+func TestSubscribeEndsWithContextAndShutdown(t *testing.T) {
+	server := NewServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	byContext, _ := server.Subscribe(ctx)
+	byShutdown, unsubscribe := server.Subscribe(context.Background())
+
+	cancel()
+	if _, ok := receive(t, byContext); ok {
+		t.Error("Expected channel closed when context is canceled")
+	}
+
+	server.Shutdown()
+	if _, ok := receive(t, byShutdown); ok {
+		t.Error("Expected channel closed on shutdown")
+	}
+	unsubscribe()
+
+	late, _ := server.Subscribe(context.Background())
+	if _, ok := receive(t, late); ok {
+		t.Error("Expected subscribing after shutdown to return a closed channel")
+	}
+}
@@ -0,0 +1,175 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrSubscriptionTooLarge is returned when the types query parameter is
+// longer than Config.MaxSubscriptionHeaderBytes
+var ErrSubscriptionTooLarge = errors.New("sse: subscription too large")
+
+// requestTypes returns the event types a connection subscribes to, from the
+// comma-separated types query parameter, falling back to the types saved
+// under its resume token. A parameter longer than maxBytes is rejected
+// when maxBytes is positive.
+func requestTypes(r *http.Request, resumed *resumeState, maxBytes int) ([]string, error) {
+	param := r.URL.Query().Get("types")
+	if param == "" {
+		if resumed != nil {
+			return resumed.types, nil
+		}
+		return nil, nil
+	}
+	if maxBytes > 0 && len(param) > maxBytes {
+		return nil, fmt.Errorf("%w: types longer than %d bytes", ErrSubscriptionTooLarge, maxBytes)
+	}
+
+	var types []string
+	seen := make(map[string]bool)
+	for _, eventType := range strings.Split(param, ",") {
+		eventType = strings.TrimSpace(eventType)
+		if eventType == "" || seen[eventType] {
+			continue
+		}
+		seen[eventType] = true
+		types = append(types, eventType)
+	}
+	return types, nil
+}
+
+// subscribeLocked adds client to the bucket of each of its types, first
+// dropping the type and room entries of previous, the client it replaces
+// under the same ID, if any. The first type becomes Client.Type. Callers
+// must hold s.mu.
+func (s *Server) subscribeLocked(client, previous *Client) {
+	if previous != nil {
+		s.unsubscribeAllLocked(previous)
+		s.leaveAllRoomsLocked(previous)
+	}
+
+	for _, eventType := range client.types {
+		bucket := s.clientsByType[eventType]
+		if bucket == nil {
+			bucket = make(map[string]*Client)
+			s.clientsByType[eventType] = bucket
+		}
+		bucket[client.ID] = client
+	}
+	if len(client.types) > 0 {
+		client.Type = client.types[0]
+	}
+}
+
+// SubscribeClient adds eventType to a connected client's subscriptions so it
+// receives BroadcastToType calls for that type without reconnecting.
+// Subscribing to a type twice is a no-op. It returns ErrClientNotFound for
+// an unknown ID.
+func (s *Server) SubscribeClient(clientID, eventType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[clientID]
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	bucket := s.clientsByType[eventType]
+	if bucket == nil {
+		bucket = make(map[string]*Client)
+		s.clientsByType[eventType] = bucket
+	}
+	if _, subscribed := bucket[clientID]; subscribed {
+		return nil
+	}
+	bucket[clientID] = client
+
+	// Copy so readers of the previous slice are unaffected
+	types := make([]string, 0, len(client.types)+1)
+	client.types = append(append(types, client.types...), eventType)
+	client.Type = client.types[0]
+	return nil
+}
+
+// UnsubscribeClient removes eventType from a connected client's
+// subscriptions. Unsubscribing from a type the client is not subscribed to
+// is a no-op. It returns ErrClientNotFound for an unknown ID.
+func (s *Server) UnsubscribeClient(clientID, eventType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.clients[clientID]; !exists {
+		return ErrClientNotFound
+	}
+
+	bucket := s.clientsByType[eventType]
+	client, subscribed := bucket[clientID]
+	if !subscribed {
+		return nil
+	}
+	delete(bucket, clientID)
+	if len(bucket) == 0 {
+		delete(s.clientsByType, eventType)
+	}
+
+	types := make([]string, 0, len(client.types))
+	for _, t := range client.types {
+		if t != eventType {
+			types = append(types, t)
+		}
+	}
+	client.types = types
+	client.Type = ""
+	if len(types) > 0 {
+		client.Type = types[0]
+	}
+	return nil
+}
+
+// renamedTypes returns a copy of types with oldType replaced by newType,
+// dropping it instead when newType is already present
+func renamedTypes(types []string, oldType, newType string) []string {
+	renamed := make([]string, 0, len(types))
+	for _, t := range types {
+		if t == oldType {
+			t = newType
+		}
+		if !slices.Contains(renamed, t) {
+			renamed = append(renamed, t)
+		}
+	}
+	return renamed
+}
+
+// unsubscribeAllLocked removes client from the buckets of its own types,
+// dropping buckets left empty, so removal costs O(subscriptions). Entries
+// already taken over by a newer client with the same ID are left alone, so
+// calling it twice is harmless. Callers must hold s.mu.
+func (s *Server) unsubscribeAllLocked(client *Client) {
+	for _, eventType := range client.types {
+		bucket := s.clientsByType[eventType]
+		if bucket[client.ID] != client {
+			continue
+		}
+		delete(bucket, client.ID)
+		if len(bucket) == 0 {
+			delete(s.clientsByType, eventType)
+		}
+	}
+}
+
+// visibleLocked filters events in place down to those the client should
+// see, dropping events broadcast to a type it is not subscribed to.
+// Callers must hold s.mu.
+func (s *Server) visibleLocked(client *Client, events []Event) []Event {
+	visible := events[:0]
+	for _, event := range events {
+		if event.target == "" || s.clientsByType[event.target][client.ID] == client {
+			visible = append(visible, event)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,184 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscribeClientAtRuntime(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	server := NewServerWithConfig(config)
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, httptest.NewRequest("GET", "/events?types=chat", http.NoBody))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	server.BroadcastToType("news", Event{Type: "news", Data: "news-before"})
+	if err := server.SubscribeClient("c1", "news"); err != nil {
+		t.Fatalf("SubscribeClient failed: %v", err)
+	}
+	if err := server.UnsubscribeClient("c1", "chat"); err != nil {
+		t.Fatalf("UnsubscribeClient failed: %v", err)
+	}
+	server.BroadcastToType("news", Event{Type: "news", Data: "news-after"})
+	server.BroadcastToType("chat", Event{Type: "chat", Data: "chat-after"})
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.mu.RLock()
+	clientType := server.clients["c1"].Type
+	server.mu.RUnlock()
+	if clientType != "news" {
+		t.Errorf("Expected Type to follow the subscriptions, got %q", clientType)
+	}
+
+	server.Shutdown()
+	<-done
+
+	body := w.Body.String()
+	if strings.Contains(body, "news-before") {
+		t.Error("Expected no news before subscribing")
+	}
+	if !strings.Contains(body, "data: news-after\n") {
+		t.Errorf("Expected news after subscribing, got %q", body)
+	}
+	if strings.Contains(body, "chat-after") {
+		t.Error("Expected no chat after unsubscribing")
+	}
+}
+
+func TestSubscribeClientUnknownID(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if err := server.SubscribeClient("ghost", "news"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound subscribing, got %v", err)
+	}
+	if err := server.UnsubscribeClient("ghost", "news"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound unsubscribing, got %v", err)
+	}
+}
+
+func TestSubscribeClientConcurrentWithBroadcast(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	client := addBareClient(server, "c1", 10000)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			_ = server.SubscribeClient("c1", "news")
+			_ = server.UnsubscribeClient("c1", "news")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			server.BroadcastToType("news", Event{Type: "news", Data: i})
+		}
+	}()
+	wg.Wait()
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if len(server.clientsByType["news"]) != 0 || len(client.types) != 0 {
+		t.Errorf("Expected no subscriptions left, got bucket=%d types=%v", len(server.clientsByType["news"]), client.types)
+	}
+}
+
+func TestRemovalTriggersRemoveOnce(t *testing.T) {
+	logger := &recordingLogger{}
+	var disconnects atomic.Int32
+	config := DefaultConfig()
+	config.BufferSize = 1
+	config.Logger = logger
+	config.ClientIDFunc = func(*http.Request) string { return "c1" }
+	config.OnDisconnect = func(string) { disconnects.Add(1) }
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &flushErrWriter{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest("GET", "/events?types=chat,news", http.NoBody).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := server.JoinRoom("c1", "lobby"); err != nil {
+		t.Fatalf("JoinRoom failed: %v", err)
+	}
+
+	// Overflow the buffer, fail the write and cancel the request at once
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			server.BroadcastToType("chat", Event{Type: "chat", Data: i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		w.broken.Store(true)
+	}()
+	go func() {
+		defer wg.Done()
+		cancel()
+	}()
+	wg.Wait()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected handler to return")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if count := server.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections, got %d", count)
+	}
+	if n := disconnects.Load(); n != 1 {
+		t.Errorf("Expected OnDisconnect once, got %d", n)
+	}
+
+	logger.mu.Lock()
+	removals := 0
+	for _, line := range logger.lines {
+		if strings.Contains(line, "sse: client c1 removed") || strings.Contains(line, "sse: client c1 disconnected") {
+			removals++
+		}
+	}
+	logger.mu.Unlock()
+	if removals != 1 {
+		t.Errorf("Expected one removal logged, got %d", removals)
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if len(server.clientsByType) != 0 {
+		t.Errorf("Expected no type buckets left, got %v", server.clientsByType)
+	}
+	if len(server.rooms) != 0 {
+		t.Errorf("Expected no rooms left, got %v", server.rooms)
+	}
+}
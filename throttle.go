@@ -0,0 +1,215 @@
+package sse
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottlePolicy decides what happens to broadcasts beyond Config.MaxBroadcastsPerSecond
+type ThrottlePolicy int
+
+const (
+	// ThrottleDrop discards broadcasts that exceed the rate
+	ThrottleDrop ThrottlePolicy = iota
+	// ThrottleDelay blocks the caller until the broadcast fits within the rate
+	ThrottleDelay
+)
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to burst
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing perSecond operations per second
+// with a burst of one second worth of operations
+func newRateLimiter(perSecond int) *rateLimiter {
+	return newBurstLimiter(perSecond, perSecond)
+}
+
+// newBurstLimiter creates a limiter allowing perSecond operations per second
+// and up to burst at once, defaulting burst to perSecond when not positive
+func newBurstLimiter(perSecond, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = perSecond
+	}
+	return &rateLimiter{
+		rate:   float64(perSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill adds tokens for the time elapsed since the last call. Callers must hold l.mu.
+func (l *rateLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// allow consumes a token if one is available
+func (l *rateLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// reserve consumes a token and returns how long the caller must wait before using it
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// throttleBroadcast applies the server-wide broadcast rate. It reports whether
+// the broadcast should proceed.
+func (s *Server) throttleBroadcast() bool {
+	if s.broadcastLimiter == nil {
+		return true
+	}
+
+	if s.config.ThrottlePolicy != ThrottleDelay {
+		if s.broadcastLimiter.allow() {
+			return true
+		}
+		s.noteThrottled()
+		return false
+	}
+
+	wait := s.broadcastLimiter.reserve()
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// throttleNoticeInterval is how long Config.NotifyThrottling collects
+// dropped broadcasts before reporting them in one throttled event
+const throttleNoticeInterval = time.Second
+
+// Throttled is the data of a "throttled" event
+type Throttled struct {
+	Dropped int64 `json:"dropped"` // broadcasts dropped by the rate limit since the last notice
+}
+
+// noteThrottled counts a broadcast dropped by the rate limit and, with
+// Config.NotifyThrottling, schedules a throttled notice unless one is
+// already pending
+func (s *Server) noteThrottled() {
+	if !s.config.NotifyThrottling {
+		return
+	}
+	s.throttledDrops.Add(1)
+	if s.throttleNotice.CompareAndSwap(false, true) {
+		time.AfterFunc(throttleNoticeInterval, s.sendThrottledNotice)
+	}
+}
+
+// sendThrottledNotice tells every client how many broadcasts were dropped
+// since the last notice
+func (s *Server) sendThrottledNotice() {
+	// Clear the flag first so a drop racing the swap schedules a new notice
+	s.throttleNotice.Store(false)
+	dropped := s.throttledDrops.Swap(0)
+	if dropped == 0 || s.ctx.Err() != nil {
+		return
+	}
+	// Like heartbeats, the notice bypasses the throttle and history
+	s.broadcastTo(Event{Type: "throttled", Data: Throttled{Dropped: dropped}}, nil, false)
+}
+
+// newClientLimiter returns a connection's Config.MaxEventsPerSecond limiter,
+// or nil when delivery is not capped
+func (s *Server) newClientLimiter() *rateLimiter {
+	if s.config.MaxEventsPerSecond <= 0 {
+		return nil
+	}
+	return newRateLimiter(s.config.MaxEventsPerSecond)
+}
+
+// paceClient waits until event may be written to client under
+// Config.MaxEventsPerSecond. Meanwhile later events wait in the client's
+// buffer, where Config.OverflowPolicy handles any overflow. Heartbeats are
+// not paced. It reports false if the server shut down or the client was
+// closed while waiting.
+func (s *Server) paceClient(client *Client, event Event) bool {
+	if client.limiter == nil || event.Type == "heartbeat" {
+		return true
+	}
+
+	wait := client.limiter.reserve()
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-client.closedSignal():
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// throttleAccept applies Config.AcceptRatePerSecond to a new connection. It
+// reports whether the connection may proceed; with ThrottleDelay it waits
+// for its turn unless the request or server is done first.
+func (s *Server) throttleAccept(r *http.Request) bool {
+	if s.acceptLimiter == nil {
+		return true
+	}
+
+	if s.config.AcceptPolicy != ThrottleDelay {
+		return s.acceptLimiter.allow()
+	}
+
+	wait := s.acceptLimiter.reserve()
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
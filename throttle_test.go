@@ -0,0 +1,232 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBroadcastsPerSecondDrop(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBroadcastsPerSecond = 10
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Tight loop well beyond the configured rate
+	for i := 0; i < 100; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	delivered := strings.Count(w.Body.String(), "event: tick")
+	if delivered == 0 || delivered > 12 {
+		t.Errorf("Expected delivered broadcasts to be capped near 10, got %d", delivered)
+	}
+}
+
+func TestMaxBroadcastsPerSecondDelay(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBroadcastsPerSecond = 20
+	config.ThrottlePolicy = ThrottleDelay
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+	elapsed := time.Since(start)
+
+	// 20 burst tokens, the remaining 10 need roughly half a second
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("Expected delayed broadcasts to take at least 400ms, took %v", elapsed)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	if delivered := strings.Count(w.Body.String(), "event: tick"); delivered != 30 {
+		t.Errorf("Expected all 30 delayed broadcasts to be delivered, got %d", delivered)
+	}
+}
+
+func TestNotifyThrottling(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBroadcastsPerSecond = 10
+	config.NotifyThrottling = true
+	server := NewServerWithConfig(config)
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	go func() {
+		server.HandleSSE(w, req)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 30; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+
+	time.Sleep(throttleNoticeInterval + 200*time.Millisecond)
+	server.Shutdown()
+
+	body := w.Body.String()
+	delivered := strings.Count(body, "event: tick")
+	if notices := strings.Count(body, "event: throttled"); notices != 1 {
+		t.Fatalf("Expected one debounced throttled notice, got %d in %q", notices, body)
+	}
+	want := fmt.Sprintf(`data: {"dropped":%d}`, 30-delivered)
+	if !strings.Contains(body, want) {
+		t.Errorf("Expected throttled notice %q, got %q", want, body)
+	}
+}
+
+func TestMaxEventsPerSecond(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxEventsPerSecond = 10
+	config.BufferSize = 10
+	config.OverflowPolicy = OverflowDropEvent
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	go server.HandleSSE(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", http.NoBody))
+	time.Sleep(100 * time.Millisecond)
+
+	// A burst storm far beyond the cap
+	for i := 0; i < 100; i++ {
+		server.Broadcast(Event{Type: "tick", Data: i})
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	// The connection event, a burst of 10, then about 5 more in half a second
+	stats := server.Stats()
+	if sent := stats.TotalEventsSent; sent < 12 || sent > 18 {
+		t.Errorf("Expected delivery capped near 16 events, got %d", sent)
+	}
+	// Beyond the burst and the buffer, the overflow policy dropped the rest
+	if stats.TotalEventsDropped < 70 {
+		t.Errorf("Expected the excess to overflow the buffer, got %d dropped", stats.TotalEventsDropped)
+	}
+	if count := server.GetConnectionCount(); count != 1 {
+		t.Errorf("Expected the paced client to stay connected, got %d connections", count)
+	}
+}
+
+func TestMaxEventsPerSecondWaitEndsOnDisconnect(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxEventsPerSecond = 1
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		server.HandleSSE(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The first tick uses the burst, so the writer waits about a second
+	server.Broadcast(Event{Type: "tick"})
+	server.Broadcast(Event{Type: "tick"})
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(300 * time.Millisecond):
+		t.Fatal("Expected a disconnect to end the pacing wait at once")
+	}
+}
+
+func TestAcceptRateDrop(t *testing.T) {
+	config := DefaultConfig()
+	config.AcceptRatePerSecond = 1
+	config.AcceptBurst = 3
+	server := NewServerWithConfig(config)
+
+	const attempts = 10
+	writers := make([]*httptest.ResponseRecorder, attempts)
+	for i := range writers {
+		writers[i] = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go server.HandleSSE(writers[i], req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if count := server.GetConnectionCount(); count != 3 {
+		t.Errorf("Expected the burst of 3 connections admitted, got %d", count)
+	}
+	server.Shutdown()
+	server.Wait()
+
+	rejected := 0
+	for _, w := range writers {
+		if w.Code == http.StatusServiceUnavailable {
+			rejected++
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After on rejected connection")
+			}
+		}
+	}
+	if rejected != attempts-3 {
+		t.Errorf("Expected %d connections rejected, got %d", attempts-3, rejected)
+	}
+}
+
+func TestAcceptRateDelay(t *testing.T) {
+	config := DefaultConfig()
+	config.AcceptRatePerSecond = 20
+	config.AcceptBurst = 1
+	config.AcceptPolicy = ThrottleDelay
+
+	admitted := make(chan time.Time, 5)
+	config.OnConnect = func(*ClientInfo) { admitted <- time.Now() }
+	server := NewServerWithConfig(config)
+	defer server.Shutdown()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go server.HandleSSE(httptest.NewRecorder(), req)
+	}
+
+	var last time.Time
+	for i := 0; i < 5; i++ {
+		select {
+		case last = <-admitted:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Only %d of 5 queued connections admitted", i)
+		}
+	}
+
+	// One connection is admitted immediately, the other four 50ms apart
+	if elapsed := last.Sub(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected admissions paced over ~200ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,123 @@
+package sse
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// trackTimeout bounds how long BroadcastTracked waits for clients to process its event
+const trackTimeout = 30 * time.Second
+
+// deliveryTracker collects per-client outcomes of a tracked broadcast and
+// reports them once every recipient has processed the event
+type deliveryTracker struct {
+	mu         sync.Mutex
+	pending    map[string]bool // recipients that have not processed the event yet
+	result     BroadcastResult
+	sealed     bool // fan-out has finished, no more recipients will be added
+	finished   bool
+	onComplete func(BroadcastResult)
+}
+
+// BroadcastTracked sends an event to all clients like Broadcast and calls
+// onComplete, in its own goroutine, once every recipient's write loop has
+// processed it. Delivered counts clients the event was written to, Dropped
+// lists clients whose buffer was full or whose write failed, and TimedOut
+// lists clients that had not processed it within 30 seconds. Clients whose
+// filter rejects the event are not reported. The event is sent as is, even
+// with Config.SplitSliceData set. onComplete is always called, with an empty
+// result when the broadcast is throttled.
+func (s *Server) BroadcastTracked(event Event, onComplete func(BroadcastResult)) {
+	tracker := &deliveryTracker{
+		pending:    make(map[string]bool),
+		onComplete: onComplete,
+	}
+
+	if s.throttleBroadcast() {
+		event.tracker = tracker
+		s.broadcastTo(event, func(c *Client) bool {
+			tracker.expect(c.ID)
+			return true
+		}, true)
+	}
+
+	tracker.seal()
+}
+
+// expect adds a recipient the event is about to be queued for
+func (t *deliveryTracker) expect(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[clientID] = true
+}
+
+// done records that clientID processed the event, successfully when err is
+// nil. It is a no-op on a nil tracker and for clients that are not pending,
+// such as those catching up from history after the broadcast completed.
+func (t *deliveryTracker) done(clientID string, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if !t.pending[clientID] {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, clientID)
+	if err == nil {
+		t.result.Delivered = append(t.result.Delivered, clientID)
+	} else {
+		t.result.Dropped = append(t.result.Dropped, clientID)
+	}
+	t.finishLocked()
+}
+
+// skip records that clientID's filter rejected the event
+func (t *deliveryTracker) skip(clientID string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.pending, clientID)
+	t.finishLocked()
+}
+
+// seal marks fan-out as finished and starts the timeout
+func (t *deliveryTracker) seal() {
+	t.mu.Lock()
+	t.sealed = true
+	if len(t.pending) > 0 {
+		time.AfterFunc(trackTimeout, t.expire)
+	}
+	t.finishLocked()
+}
+
+// expire reports every recipient still pending as timed out
+func (t *deliveryTracker) expire() {
+	t.mu.Lock()
+	for clientID := range t.pending {
+		t.result.TimedOut = append(t.result.TimedOut, clientID)
+	}
+	t.pending = nil
+	t.finishLocked()
+}
+
+// finishLocked calls onComplete once all recipients are accounted for. It
+// must be called with t.mu held and releases it.
+func (t *deliveryTracker) finishLocked() {
+	if t.finished || !t.sealed || len(t.pending) > 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.finished = true
+	result := t.result
+	t.mu.Unlock()
+
+	sort.Strings(result.Delivered)
+	sort.Strings(result.Dropped)
+	sort.Strings(result.TimedOut)
+	go t.onComplete(result)
+}
@@ -0,0 +1,85 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBroadcastTracked(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	targets := []string{
+		"/events",
+		"/events",
+		"/events?filter=" + url.QueryEscape("type=='other'"),
+	}
+	for _, target := range targets {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		go server.HandleSSE(httptest.NewRecorder(), req)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	slow := addBareClient(server, "slow", 1)
+	slow.EventCh <- Event{Data: "backlog"}
+
+	results := make(chan BroadcastResult, 1)
+	server.BroadcastTracked(Event{Type: "notice", Data: "hello"}, func(result BroadcastResult) {
+		results <- result
+	})
+
+	select {
+	case result := <-results:
+		if len(result.Delivered) != 2 {
+			t.Errorf("Expected 2 delivered clients, got %v", result.Delivered)
+		}
+		if len(result.Dropped) != 1 || result.Dropped[0] != "slow" {
+			t.Errorf("Expected the slow client to be dropped, got %v", result.Dropped)
+		}
+		if len(result.TimedOut) != 0 {
+			t.Errorf("Expected no timeouts, got %v", result.TimedOut)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected onComplete to be called")
+	}
+}
+
+func TestBroadcastTrackedNoClients(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	results := make(chan BroadcastResult, 1)
+	server.BroadcastTracked(Event{Data: "nobody"}, func(result BroadcastResult) {
+		results <- result
+	})
+
+	select {
+	case result := <-results:
+		if len(result.Delivered)+len(result.Dropped)+len(result.TimedOut) != 0 {
+			t.Errorf("Expected an empty result, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected onComplete to be called with no clients")
+	}
+}
+
+func TestDeliveryTrackerExpire(t *testing.T) {
+	results := make(chan BroadcastResult, 1)
+	tracker := &deliveryTracker{
+		pending:    map[string]bool{"a": true, "b": true},
+		sealed:     true,
+		onComplete: func(result BroadcastResult) { results <- result },
+	}
+
+	tracker.done("a", nil)
+	tracker.expire()
+	tracker.done("b", nil) // late outcomes are ignored
+
+	result := <-results
+	if len(result.Delivered) != 1 || len(result.TimedOut) != 1 || result.TimedOut[0] != "b" {
+		t.Errorf("Expected a delivered and b timed out, got %+v", result)
+	}
+}
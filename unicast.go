@@ -0,0 +1,29 @@
+package sse
+
+// SendToClient queues an event for a single client, for request/response
+// patterns where the server knows which connection should get the reply.
+// The event is not recorded in history or given an ID. It returns
+// ErrClientNotFound for an unknown ID, the ValidateEvent error for data
+// that does not match a registered schema, and ErrClientBufferFull when the
+// client's buffer is full; in that case the event is not queued and,
+// unlike a broadcast, the client is not disconnected.
+func (s *Server) SendToClient(clientID string, event Event) error {
+	if err := s.ValidateEvent(event); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	event.Data = snapshotData(event.Data)
+	event.route = routeDirect
+	if !client.enqueue(event) {
+		return ErrClientBufferFull
+	}
+	return nil
+}
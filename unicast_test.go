@@ -0,0 +1,67 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendToClient(t *testing.T) {
+	config := DefaultConfig()
+	ids := make(chan string, 2)
+	ids <- "alice"
+	ids <- "bob"
+	config.ClientIDFunc = func(*http.Request) string { return <-ids }
+	server := NewServerWithConfig(config)
+
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	done := make(chan struct{}, 2)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		go func(w *httptest.ResponseRecorder) {
+			server.HandleSSE(w, req)
+			done <- struct{}{}
+		}(recorders[i])
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := server.SendToClient("bob", Event{Type: "reply", Data: "for bob"}); err != nil {
+		t.Fatalf("SendToClient failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+	<-done
+	<-done
+
+	if body := recorders[0].Body.String(); strings.Contains(body, "for bob") {
+		t.Errorf("Expected alice not to receive bob's event, got %q", body)
+	}
+	if body := recorders[1].Body.String(); !strings.Contains(body, "event: reply\ndata: for bob\n") {
+		t.Errorf("Expected bob to receive the event, got %q", body)
+	}
+}
+
+func TestSendToClientErrors(t *testing.T) {
+	server := NewServer()
+	defer server.Shutdown()
+
+	if err := server.SendToClient("missing", Event{Data: "x"}); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	addBareClient(server, "bare", 1)
+	if err := server.SendToClient("bare", Event{Data: "first"}); err != nil {
+		t.Fatalf("Expected the first event to be queued, got %v", err)
+	}
+	if err := server.SendToClient("bare", Event{Data: "second"}); !errors.Is(err, ErrClientBufferFull) {
+		t.Errorf("Expected ErrClientBufferFull, got %v", err)
+	}
+	if server.GetConnectionCount() != 1 {
+		t.Error("Expected a full buffer to leave the client connected")
+	}
+}
@@ -0,0 +1,77 @@
+package sse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// clientVersionHeader carries the protocol version a client advertises at connect
+const clientVersionHeader = "X-Client-Version"
+
+// BroadcastToVersion sends an event to every client that advertised at least
+// minVersion in its X-Client-Version header, so newer event types do not reach
+// clients that cannot handle them. Versions are dot-separated numbers with an
+// optional "v" prefix, compared numerically part by part, so 1.10 is newer
+// than 1.9; anything after a "-" or "+" is ignored. Clients without a
+// parsable version are treated as older than any minVersion. An empty
+// minVersion matches every client.
+func (s *Server) BroadcastToVersion(minVersion string, event Event) {
+	if minVersion == "" {
+		s.BroadcastFunc(func(*ClientInfo) bool { return true }, event)
+		return
+	}
+	floor, ok := parseVersion(minVersion)
+	if !ok {
+		s.logger.Warnf("sse: BroadcastToVersion: invalid minimum version %q", minVersion)
+		return
+	}
+	event.route = "version>=" + minVersion
+
+	s.BroadcastFunc(func(c *ClientInfo) bool {
+		v, ok := parseVersion(c.Version)
+		return ok && compareVersions(v, floor) >= 0
+	}, event)
+}
+
+// parseVersion splits a version such as "v1.4.2-beta" into its numeric parts
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return nil, false
+	}
+
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, equal to or newer
+// than b, treating missing trailing parts as zero
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcastToVersion(t *testing.T) {
+	server := NewServer()
+
+	versions := []string{"", "1.2.0", "1.9", "1.10.3", "v2.0.0-beta", "garbage"}
+	recorders := make(map[string]*httptest.ResponseRecorder)
+	for _, version := range versions {
+		req := httptest.NewRequest("GET", "/events", http.NoBody)
+		if version != "" {
+			req.Header.Set("X-Client-Version", version)
+		}
+		w := httptest.NewRecorder()
+		recorders[version] = w
+		go server.HandleSSE(w, req)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.BroadcastToVersion("1.10", Event{Type: "new-feature", Data: "gated"})
+
+	time.Sleep(100 * time.Millisecond)
+	server.Shutdown()
+
+	want := map[string]bool{"1.10.3": true, "v2.0.0-beta": true}
+	for _, version := range versions {
+		got := strings.Contains(recorders[version].Body.String(), "data: gated")
+		if got != want[version] {
+			t.Errorf("Client with version %q: expected delivery %v, got %v", version, want[version], got)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10", "1.9", 1},
+		{"1.9", "1.10", -1},
+		{"1.2", "1.2.0", 0},
+		{"v2", "2.0.0", 0},
+		{"2.0.0-rc1", "2.0.0", 0},
+		{"1.2.3+build", "1.2.4", -1},
+	}
+
+	for _, tt := range tests {
+		a, okA := parseVersion(tt.a)
+		b, okB := parseVersion(tt.b)
+		if !okA || !okB {
+			t.Fatalf("Expected %q and %q to parse", tt.a, tt.b)
+		}
+		if got := compareVersions(a, b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "v", "1.x", "1..2", "-1"} {
+		if _, ok := parseVersion(invalid); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
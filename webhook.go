@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// webhookMaxAttempts is how many times a webhook POST is tried before the event is dropped
+	webhookMaxAttempts = 5
+	// webhookBaseBackoff is the delay before the first retry, doubled on each subsequent one
+	webhookBaseBackoff = 100 * time.Millisecond
+	// webhookTimeout bounds a single webhook POST
+	webhookTimeout = 10 * time.Second
+)
+
+// webhook is a subscriber that receives events as HTTP POSTs instead of a stream
+type webhook struct {
+	url    string
+	types  map[string]bool // empty accepts every type
+	queue  chan Event
+	client *http.Client
+}
+
+// RegisterWebhook forwards every published event whose type is in types (or
+// every event when types is empty) to url as a JSON POST. Failed deliveries
+// are retried with exponential backoff; events are dropped if the webhook's
+// queue is full or all attempts fail.
+func (s *Server) RegisterWebhook(url string, types []string) {
+	hook := &webhook{
+		url:    url,
+		types:  make(map[string]bool, len(types)),
+		queue:  make(chan Event, s.config.BufferSize),
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+	for _, t := range types {
+		hook.types[t] = true
+	}
+
+	s.mu.Lock()
+	s.webhooks = append(s.webhooks, hook)
+	s.mu.Unlock()
+
+	go hook.run(s.ctx)
+}
+
+// notifyWebhooks queues event for every webhook subscribed to its type
+func (s *Server) notifyWebhooks(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, hook := range s.webhooks {
+		if len(hook.types) > 0 && !hook.types[event.Type] {
+			continue
+		}
+		select {
+		case hook.queue <- event:
+		default:
+			// Queue is full, drop the event for this webhook
+		}
+	}
+}
+
+// run delivers queued events until ctx is canceled
+func (h *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case event := <-h.queue:
+			h.deliver(ctx, event)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver POSTs an event, retrying with exponential backoff
+func (h *webhook) deliver(ctx context.Context, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	backoff := webhookBaseBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if err := h.post(ctx, body); err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single webhook request
+func (h *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
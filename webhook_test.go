@@ -0,0 +1,84 @@
+package sse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records POSTed events, failing the first failures requests
+type webhookReceiver struct {
+	mu       sync.Mutex
+	events   []Event
+	failures int
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	if rcv.failures > 0 {
+		rcv.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var event Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.events = append(rcv.events, event)
+}
+
+func (rcv *webhookReceiver) received() []Event {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]Event(nil), rcv.events...)
+}
+
+func TestRegisterWebhook(t *testing.T) {
+	rcv := &webhookReceiver{}
+	receiver := httptest.NewServer(rcv)
+	defer receiver.Close()
+
+	server := NewServer()
+	defer server.Shutdown()
+
+	server.RegisterWebhook(receiver.URL, []string{"alert"})
+
+	server.Broadcast(Event{Type: "info", Data: "ignored"})
+	server.Broadcast(Event{Type: "alert", Data: "disk full", ID: "a-1"})
+
+	time.Sleep(200 * time.Millisecond)
+
+	events := rcv.received()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 webhook delivery, got %d", len(events))
+	}
+	if events[0].Type != "alert" || events[0].Data != "disk full" || events[0].ID != "a-1" {
+		t.Errorf("Unexpected webhook payload: %+v", events[0])
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	rcv := &webhookReceiver{failures: 2}
+	receiver := httptest.NewServer(rcv)
+	defer receiver.Close()
+
+	server := NewServer()
+	defer server.Shutdown()
+
+	server.RegisterWebhook(receiver.URL, nil)
+	server.Broadcast(Event{Type: "update", Data: "retry me"})
+
+	// Two failures back off 100ms then 200ms before the third attempt
+	time.Sleep(600 * time.Millisecond)
+
+	if events := rcv.received(); len(events) != 1 {
+		t.Errorf("Expected delivery after retries, got %d events", len(events))
+	}
+}